@@ -1,12 +1,11 @@
 package ipdb
 
 import (
-	"errors"
-	"fmt"
+	"context"
+	"io"
+	"io/fs"
 	"net"
-	"os"
-	"sync"
-	"time"
+	"net/netip"
 )
 
 // IPInfo 定义通用IP信息接口
@@ -41,163 +40,142 @@ func (b *BaseStationInfo) GetCityName() string {
 
 // BaseStation 基站数据库结构体
 type BaseStation struct {
-	reader *reader
-	mu     sync.RWMutex // 保护并发访问
-	cache  *sync.Map    // 添加缓存
+	database
+}
+
+// newBaseStationDB 使用 reader 创建数据库实例
+func newBaseStationDB(r *reader) (*BaseStation, error) {
+	db := &BaseStation{}
+	if err := db.init(r, &BaseStationInfo{}, ProductBaseStation); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 // NewBaseStation 创建新的基站数据库实例
 func NewBaseStation(name string) (*BaseStation, error) {
 	r, e := newReader(name, &BaseStationInfo{})
 	if e != nil {
-		return nil, e
+		return nil, msgInit.wrap(e, "BaseStation")
 	}
 
-	return &BaseStation{
-		reader: r,
-		cache:  &sync.Map{},
-	}, nil
+	return newBaseStationDB(r)
 }
 
-// Reload 重新加载数据库文件
-func (db *BaseStation) Reload(name string) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	_, err := os.Stat(name)
-	if err != nil {
-		return err
-	}
-
-	reader, err := newReader(name, &BaseStationInfo{})
-	if err != nil {
-		return err
+// NewBaseStationMmap 通过 mmap 加载基站数据库, 使用完毕后调用 Close 释放
+func NewBaseStationMmap(name string) (*BaseStation, error) {
+	r, e := newReaderMmap(name, &BaseStationInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "BaseStation")
 	}
 
-	db.reader = reader
-	return nil
+	return newBaseStationDB(r)
 }
 
-// Find 查找IP地址对应的基站信息(字符串切片形式)
-func (db *BaseStation) Find(addr, language string) ([]string, error) {
-	if net.ParseIP(addr) == nil {
-		return nil, ErrInvalidIP
+// NewBaseStationFromBytes 从字节数据创建基站数据库实例
+func NewBaseStationFromBytes(bs []byte) (*BaseStation, error) {
+	r, e := newReaderFromBytes(bs, &BaseStationInfo{})
+	if e != nil {
+		return nil, msgInitBytes.wrap(e, "BaseStation")
 	}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	return newBaseStationDB(r)
+}
 
-	result, err := db.reader.find1(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
+// NewBaseStationFromReader 从 io.Reader 创建基站数据库实例, maxSize 大于 0 时限制读取的最大字节数
+func NewBaseStationFromReader(rd io.Reader, maxSize int64) (*BaseStation, error) {
+	r, e := newReaderFromReader(rd, maxSize, &BaseStationInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "BaseStation")
 	}
 
-	return result, nil
+	return newBaseStationDB(r)
 }
 
-// FindMap 查找IP地址对应的基站信息(Map形式)
-func (db *BaseStation) FindMap(addr, language string) (map[string]string, error) {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	data, err := db.reader.find1(addr, language)
-	if err != nil {
-		return nil, err
+// NewBaseStationFromReaderAt 从 io.ReaderAt 读取 size 字节创建基站数据库实例
+func NewBaseStationFromReaderAt(rd io.ReaderAt, size int64) (*BaseStation, error) {
+	r, e := newReaderFromReaderAt(rd, size, &BaseStationInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "BaseStation")
 	}
 
-	info := make(map[string]string, len(db.reader.meta.Fields))
-	for k, v := range data {
-		info[db.reader.meta.Fields[k]] = v
+	return newBaseStationDB(r)
+}
+
+// NewBaseStationFromFS 从 fs.FS 中的文件创建基站数据库实例, 可用于 embed.FS
+func NewBaseStationFromFS(fsys fs.FS, name string) (*BaseStation, error) {
+	r, e := newReaderFromFS(fsys, name, &BaseStationInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "BaseStation")
 	}
 
-	return info, nil
+	return newBaseStationDB(r)
 }
 
 // FindInfo 查找IP地址对应的基站信息(结构体形式)
 func (db *BaseStation) FindInfo(addr, language string) (*BaseStationInfo, error) {
-	// 先查缓存
-	if info, ok := db.cache.Load(addr + language); ok {
-		return info.(*BaseStationInfo), nil
-	}
-
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	data, err := db.reader.FindMap(addr, language)
-	if err != nil {
-		return nil, err
-	}
+	return db.FindInfoContext(context.Background(), addr, language)
+}
 
-	info := &BaseStationInfo{
-		CountryName: data["country_name"],
-		RegionName:  data["region_name"],
-		CityName:    data["city_name"],
-		OwnerDomain: data["owner_domain"],
-		IspDomain:   data["isp_domain"],
-		BaseStation: data["base_station"],
-	}
+// FindInfoContext 与 FindInfo 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *BaseStation) FindInfoContext(ctx context.Context, addr, language string) (*BaseStationInfo, error) {
+	info, _, err := findInfo(ctx, &db.database, addr, language, decodeBaseStationInfo)
+	return info, err
+}
 
-	// 写入缓存
-	db.cache.Store(addr+language, info)
+// decodeBaseStationInfo 将字段映射解析为 BaseStationInfo
+func decodeBaseStationInfo(r *reader, data []string) *BaseStationInfo {
+	info := &BaseStationInfo{}
+	r.fill(info, data)
+	return info
+}
 
-	return info, nil
+// FindInfoWithNetwork 查找IP地址对应的基站信息, 同时返回命中的网络
+func (db *BaseStation) FindInfoWithNetwork(addr, language string) (*BaseStationInfo, *net.IPNet, error) {
+	return findInfo(context.Background(), &db.database, addr, language, decodeBaseStationInfo)
 }
 
-// IsIPv4 检查是否支持IPv4
-func (db *BaseStation) IsIPv4() bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.IsIPv4Support()
+// FindInfoAddr 使用 netip.Addr 查找基站信息(结构体形式)
+func (db *BaseStation) FindInfoAddr(addr netip.Addr, language string) (*BaseStationInfo, error) {
+	info, _, err := db.FindInfoWithPrefix(addr, language)
+	return info, err
 }
 
-// IsIPv6 检查是否支持IPv6
-func (db *BaseStation) IsIPv6() bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.IsIPv6Support()
+// FindInfoWithPrefix 使用 netip.Addr 查找基站信息, 同时返回命中的网络前缀
+func (db *BaseStation) FindInfoWithPrefix(addr netip.Addr, language string) (*BaseStationInfo, netip.Prefix, error) {
+	return findInfoAddr(&db.database, addr, language, decodeBaseStationInfo)
 }
 
-// Languages 返回支持的语言列表
-func (db *BaseStation) Languages() []string {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.Languages()
+// FindRange 返回 prefix 范围内的每个网络及其记录, 按地址顺序排列.
+// prefix 落在一个更大的网络中时, 返回 prefix 本身及该网络的记录.
+func (db *BaseStation) FindRange(prefix netip.Prefix, language string) ([]RangeItem[BaseStationInfo], error) {
+	return db.FindRangeContext(context.Background(), prefix, language)
 }
 
-// Fields 返回支持的字段列表
-func (db *BaseStation) Fields() []string {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.meta.Fields
+// FindRangeContext 与 FindRange 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (db *BaseStation) FindRangeContext(ctx context.Context, prefix netip.Prefix, language string) ([]RangeItem[BaseStationInfo], error) {
+	return findRange(ctx, &db.database, prefix, language, decodeBaseStationInfo)
 }
 
-// BuildTime 返回数据库构建时间
-func (db *BaseStation) BuildTime() time.Time {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.Build()
+// Walk 遍历数据库中的每个网络及其基站信息, fn 返回 false 时停止遍历
+func (db *BaseStation) Walk(language string, fn func(network *net.IPNet, info *BaseStationInfo) bool) error {
+	return db.WalkContext(context.Background(), language, fn)
 }
 
-type BatchResult struct {
-	IP    string
-	Info  *BaseStationInfo
-	Error error
+// WalkContext 与 Walk 相同, 遍历过程中 ctx 取消时停止并返回 ctx 的错误
+func (db *BaseStation) WalkContext(ctx context.Context, language string, fn func(network *net.IPNet, info *BaseStationInfo) bool) error {
+	return walkInfo(ctx, &db.database, language, decodeBaseStationInfo, fn)
 }
 
+// BatchResult BaseStation 批量查询的结果
+type BatchResult = BatchItem[BaseStationInfo]
+
+// BatchFind 并发查询多个地址, 按输入顺序返回每个地址的结果和错误, 所有地址使用同一个数据库快照
 func (db *BaseStation) BatchFind(addrs []string, language string) []BatchResult {
-	results := make([]BatchResult, len(addrs))
-	for i, addr := range addrs {
-		info, err := db.FindInfo(addr, language)
-		results[i] = BatchResult{
-			IP:    addr,
-			Info:  info,
-			Error: err,
-		}
-	}
-	return results
+	return db.BatchFindContext(context.Background(), addrs, language)
 }
 
-var (
-	ErrInvalidIP = errors.New("无效的IP地址")
-	ErrNotFound  = errors.New("未找到IP信息")
-)
+// BatchFindContext 与 BatchFind 相同, ctx 取消后尚未查询的地址返回 ctx 的错误
+func (db *BaseStation) BatchFindContext(ctx context.Context, addrs []string, language string) []BatchResult {
+	return batchFind(ctx, &db.database, addrs, language, decodeBaseStationInfo)
+}
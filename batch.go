@@ -0,0 +1,199 @@
+package ipdb
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"math/bits"
+	"net/netip"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// BatchWorkers 批量查询的最大并发数, 小于等于 0 时使用 runtime.GOMAXPROCS(0)
+var BatchWorkers = 0
+
+// batchChunkSize 每个并发任务处理的连续地址数量, 排序后相邻的地址大多落在同一网络中
+const batchChunkSize = 256
+
+// BatchItem 批量查询中单个地址的结果, Error 不为 nil 时 Info 为 nil
+type BatchItem[T any] struct {
+	IP      string       // 输入的地址
+	Info    *T           // 解析后的记录
+	Network netip.Prefix // 命中的网络
+	Error   error        // 查询失败的原因, 为 *LookupError
+}
+
+// batchKey 排序用的地址及其在输入中的下标
+type batchKey struct {
+	hi, lo uint64
+	index  int
+}
+
+// addr 还原为 netip.Addr, IPv4 映射地址还原为 IPv4 地址
+func (k batchKey) addr() netip.Addr {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], k.hi)
+	binary.BigEndian.PutUint64(b[8:], k.lo)
+	return netip.AddrFrom16(b).Unmap()
+}
+
+// batchCursor 记录上一个地址在树中经过的节点, 排序后的下一个地址从两者的公共前缀处继续查找
+type batchCursor struct {
+	r     *reader
+	bits  int      // 上一个地址的位数, 0 表示尚未查找
+	ip    [16]byte // 上一个地址
+	path  [129]int // path[d] 为上一个地址消耗 d 位后到达的节点
+	depth int      // 上一个地址查找停止时消耗的位数
+}
+
+// locate 与 reader.locateAddr 相同, 只是从与上一个地址的公共前缀处继续查找
+func (c *batchCursor) locate(addr netip.Addr) (int, netip.Prefix, error) {
+	var ip []byte
+	var bitCount, start int
+	if addr.Is4() {
+		if !c.r.IsIPv4Support() {
+			return -1, netip.Prefix{}, ErrNoSupportIPv4
+		}
+		b := addr.As4()
+		ip, bitCount, start = b[:], 32, c.r.v4offset
+	} else {
+		if !c.r.IsIPv6Support() {
+			return -1, netip.Prefix{}, ErrNoSupportIPv6
+		}
+		b := addr.As16()
+		ip, bitCount, start = b[:], 128, 0
+	}
+
+	from := 0
+	if bitCount == c.bits {
+		from = min(commonBits(c.ip[:len(ip)], ip), c.depth)
+	} else {
+		c.bits, c.path[0] = bitCount, start
+	}
+	copy(c.ip[:], ip)
+
+	node, prefix := c.r.descend(ip, bitCount, c.path[:bitCount+1], from)
+	c.depth = prefix
+	if node <= c.r.nodeCount {
+		return -1, netip.Prefix{}, ErrDataNotExists
+	}
+
+	network, err := addr.Prefix(prefix)
+	if err != nil {
+		return -1, netip.Prefix{}, err
+	}
+	return node, network, nil
+}
+
+// commonBits 返回 a 和 b 相同的前导位数
+func commonBits(a, b []byte) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return i*8 + bits.LeadingZeros8(x)
+		}
+	}
+	return len(a) * 8
+}
+
+// batchFind 批量查询: 解析地址后按地址排序下标, 分段交给有限数量的 goroutine 查询.
+// 排序后相邻的地址共享前缀, 每个地址从与前一个地址的公共前缀处继续沿树查找, 不再从树根开始;
+// 与前一个地址相同或命中同一网络时直接复用结果.
+// 结果按输入顺序返回, ctx 取消后尚未查询的地址返回 ctx 的错误.
+func batchFind[T any](ctx context.Context, db *database, addrs []string, language string, decode decodeFunc[T]) []BatchItem[T] {
+	results := make([]BatchItem[T], len(addrs))
+	s, err := db.acquire()
+	if err != nil {
+		for i, ip := range addrs {
+			results[i] = BatchItem[T]{IP: ip, Error: db.lookupError(ip, language, err)}
+		}
+		return results
+	}
+	defer s.reader.release()
+
+	// order 为有效地址按地址排序后的结果, 只保存 128 位地址和输入下标, 排序时直接比较整数
+	order := make([]batchKey, 0, len(addrs))
+	for i, ip := range addrs {
+		results[i].IP = ip
+		addr, err := netip.ParseAddr(ip)
+		if err != nil || addr.Zone() != "" {
+			results[i].Error = s.lookupError(ip, language, ErrIPFormat)
+			continue
+		}
+		b := addr.As16()
+		order = append(order, batchKey{hi: binary.BigEndian.Uint64(b[:8]), lo: binary.BigEndian.Uint64(b[8:]), index: i})
+	}
+	slices.SortFunc(order, func(x, y batchKey) int {
+		if x.hi != y.hi {
+			return cmp.Compare(x.hi, y.hi)
+		}
+		return cmp.Compare(x.lo, y.lo)
+	})
+
+	chunks := (len(order) + batchChunkSize - 1) / batchChunkSize
+	workers := BatchWorkers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > chunks {
+		workers = chunks
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range next {
+				end := start + batchChunkSize
+				if end > len(order) {
+					end = len(order)
+				}
+
+				var last BatchItem[T]
+				cursor := batchCursor{r: s.reader}
+				find := func(addr netip.Addr) (*T, netip.Prefix, error) {
+					node, network, err := cursor.locate(addr)
+					if err != nil {
+						return nil, netip.Prefix{}, err
+					}
+					info, err := cachedInfo(s, node, language, decode)
+					return info, network, err
+				}
+				for k, key := range order[start:end] {
+					i, addr := key.index, key.addr()
+					item := last
+					switch {
+					case k > 0 && key.hi == order[start+k-1].hi && key.lo == order[start+k-1].lo:
+						// 与上一个地址相同
+					case ctx.Err() != nil:
+						item = BatchItem[T]{Error: ctx.Err()}
+					case last.Error == nil && last.Network.IsValid() && last.Network.Contains(addr):
+						// 与上一个地址位于同一网络
+					default:
+						item.Info, item.Network, item.Error = find(addr)
+						if item.Error != nil {
+							item.Info = nil
+						}
+					}
+					last = item
+
+					results[i].Info = item.Info
+					results[i].Network = item.Network
+					if item.Error != nil {
+						results[i].Error = s.lookupError(results[i].IP, language, item.Error)
+					}
+				}
+			}
+		}()
+	}
+	for start := 0; start < len(order); start += batchChunkSize {
+		next <- start
+	}
+	close(next)
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,126 @@
+package ipdb_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/netip"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCity_BatchFind(t *testing.T) {
+	defer func(n int) { ipdb.BatchWorkers = n }(ipdb.BatchWorkers)
+	ipdb.BatchWorkers = 3
+
+	rnd := rand.New(rand.NewSource(1))
+	addrs := []string{"bad", "1.1.1.1", "::ffff:1.1.1.1", "1.1.1.1", "fe80::1%eth0"}
+	for i := 0; i < 2000; i++ {
+		addrs = append(addrs, fmt.Sprintf("%d.%d.%d.%d", rnd.Intn(256), rnd.Intn(256), rnd.Intn(256), rnd.Intn(256)))
+	}
+	// 同一网络中的连续地址
+	for i := 0; i < 100; i++ {
+		addrs = append(addrs, fmt.Sprintf("114.114.114.%d", i))
+	}
+
+	results := db.BatchFind(addrs, "CN")
+	require.Len(t, results, len(addrs))
+	for i, r := range results {
+		assert.Equal(t, addrs[i], r.IP)
+
+		addr, err := netip.ParseAddr(addrs[i])
+		if err != nil || addr.Zone() != "" {
+			assert.ErrorIs(t, r.Error, ipdb.ErrIPFormat, addrs[i])
+			continue
+		}
+		info, network, err := db.FindInfoWithPrefix(addr, "CN")
+		if err != nil {
+			assert.ErrorIs(t, r.Error, ipdb.ErrNotFound, addrs[i])
+			assert.Nil(t, r.Info)
+			continue
+		}
+		require.NoError(t, r.Error, addrs[i])
+		assert.Equal(t, info, r.Info, addrs[i])
+		assert.Equal(t, network, r.Network, addrs[i])
+	}
+	assert.Equal(t, results[1].Info, results[2].Info)
+
+	var le *ipdb.LookupError
+	require.ErrorAs(t, results[0].Error, &le)
+	assert.Equal(t, "bad", le.IP)
+
+	assert.Empty(t, db.BatchFind(nil, "CN"))
+}
+
+func TestBatchFindCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := db.BatchFindContext(ctx, []string{"1.1.1.1", "bad"}, "CN")
+	assert.ErrorIs(t, results[0].Error, context.Canceled)
+	assert.ErrorIs(t, results[1].Error, ipdb.ErrIPFormat)
+}
+
+func TestBatchFindTypes(t *testing.T) {
+	addrs := []string{"1.2.3.4", "8.8.8.8", "2001:db8::1"}
+	check := func(t *testing.T, errs ...error) {
+		require.Len(t, errs, 3)
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], ipdb.ErrNotFound)
+		assert.NoError(t, errs[2])
+	}
+
+	risk, err := ipdb.NewRiskFromBytes(buildProductDB(t, productFields[ipdb.ProductRisk]...))
+	require.NoError(t, err)
+	rr := risk.BatchFind(addrs)
+	check(t, rr[0].Error, rr[1].Error, rr[2].Error)
+	assert.Equal(t, "CN:behavior", rr[0].Info.Behavior)
+
+	idc, err := ipdb.NewIDCFromBytes(buildProductDB(t, productFields[ipdb.ProductIDC]...))
+	require.NoError(t, err)
+	ir := idc.BatchFind(addrs, "EN")
+	check(t, ir[0].Error, ir[1].Error, ir[2].Error)
+	assert.Equal(t, "EN:idc", ir[2].Info.IDC)
+
+	typed, err := ipdb.OpenBytes[customInfo](buildCustomDB(t))
+	require.NoError(t, err)
+	tr := typed.BatchFind([]string{"1.2.3.4"}, "CN")
+	require.NoError(t, tr[0].Error)
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"), tr[0].Network)
+}
+
+// benchAddrs 随机生成 n 个 IPv4 地址
+func benchAddrs(n int) []string {
+	rnd := rand.New(rand.NewSource(1))
+	addrs := make([]string, n)
+	for i := range addrs {
+		addrs[i] = fmt.Sprintf("%d.%d.%d.%d", rnd.Intn(256), rnd.Intn(256), rnd.Intn(256), rnd.Intn(256))
+	}
+	return addrs
+}
+
+func BenchmarkCity_BatchFind(b *testing.B) {
+	addrs := benchAddrs(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		db.BatchFind(addrs, "CN")
+	}
+}
+
+// BenchmarkCity_FindInfoAddrLoop 与 BenchmarkCity_BatchFind 相同的地址逐个串行查询, 作为对比
+func BenchmarkCity_FindInfoAddrLoop(b *testing.B) {
+	addrs := benchAddrs(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, s := range addrs {
+			addr, err := netip.ParseAddr(s)
+			if err != nil {
+				b.Fatal(err)
+			}
+			_, _ = db.FindInfoAddr(addr, "CN")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package ipdb
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheSize 数据库实例默认缓存的最大条目数
+const DefaultCacheSize = 65536
+
+// CacheKey 缓存键. 大量地址会命中同一条记录, 因此按记录在数据区中的偏移和语言缓存,
+// 每条记录只解析一次, "::ffff:1.2.3.4" 与 "1.2.3.4" 等不同写法也能共享结果.
+type CacheKey struct {
+	Offset   int
+	Language string
+}
+
+// Cache 查询结果缓存, 实现需要支持并发访问
+type Cache interface {
+	Get(key CacheKey) (interface{}, bool)
+	Set(key CacheKey, value interface{})
+	Clear()
+	Stats() CacheStats
+}
+
+// CacheStats 缓存命中统计
+type CacheStats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`   // 因容量不足被淘汰的条目数
+	Expirations uint64 `json:"expirations"` // 因过期被删除的条目数
+	Len         int    `json:"len"`
+}
+
+// newCache 返回数据库实例默认使用的缓存
+func newCache() Cache {
+	return NewClockCache(DefaultCacheSize, 0)
+}
+
+// noCache 不缓存任何内容, 用于关闭缓存
+type noCache struct{}
+
+func (noCache) Get(CacheKey) (interface{}, bool) { return nil, false }
+func (noCache) Set(CacheKey, interface{})        {}
+func (noCache) Clear()                           {}
+func (noCache) Stats() CacheStats                { return CacheStats{} }
+
+// ClockCache 容量有限的 CLOCK (second chance) 缓存, 可选过期时间. 淘汰顺序近似 LRU, 但不是严格的 LRU:
+// 命中时只在读锁下设置访问标记, 不调整链表, 命中之间不会互相阻塞; 写入时获取写锁.
+// 容量不足时从最久未调整的一端淘汰, 被访问过的条目清除标记后移到表头, 获得一次保留的机会.
+type ClockCache struct {
+	mu    sync.RWMutex
+	size  int
+	ttl   time.Duration
+	ll    *list.List
+	items map[CacheKey]*list.Element
+
+	hits        atomic.Uint64
+	misses      atomic.Uint64
+	evictions   uint64 // 由 mu 保护
+	expirations uint64 // 由 mu 保护
+}
+
+type clockEntry struct {
+	key        CacheKey
+	value      interface{}
+	expires    time.Time
+	referenced atomic.Bool // 上次淘汰检查之后是否被访问过
+}
+
+// NewClockCache 创建最多保存 size 个条目的 CLOCK 缓存, ttl 大于 0 时条目在 ttl 后过期
+func NewClockCache(size int, ttl time.Duration) *ClockCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+	return &ClockCache{
+		size:  size,
+		ttl:   ttl,
+		ll:    list.New(),
+		items: make(map[CacheKey]*list.Element),
+	}
+}
+
+func (c *ClockCache) Get(key CacheKey) (interface{}, bool) {
+	c.mu.RLock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.RUnlock()
+		c.misses.Add(1)
+		return nil, false
+	}
+	e := el.Value.(*clockEntry)
+	if c.ttl > 0 && time.Now().After(e.expires) {
+		c.mu.RUnlock()
+		c.expire(key)
+		c.misses.Add(1)
+		return nil, false
+	}
+	if !e.referenced.Load() {
+		e.referenced.Store(true)
+	}
+	value := e.value
+	c.mu.RUnlock()
+
+	c.hits.Add(1)
+	return value, true
+}
+
+// expire 删除已过期的条目, 加写锁后重新检查, 期间可能已被其他调用删除或更新
+func (c *ClockCache) expire(key CacheKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok && time.Now().After(el.Value.(*clockEntry).expires) {
+		c.remove(el)
+		c.expirations++
+	}
+}
+
+func (c *ClockCache) Set(key CacheKey, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expires time.Time
+	if c.ttl > 0 {
+		expires = time.Now().Add(c.ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*clockEntry)
+		e.value = value
+		e.expires = expires
+		e.referenced.Store(true)
+		return
+	}
+
+	// 先腾出空间再插入, 新条目不会被立即淘汰
+	for c.ll.Len() >= c.size {
+		el := c.ll.Back()
+		if e := el.Value.(*clockEntry); e.referenced.Load() {
+			e.referenced.Store(false)
+			c.ll.MoveToFront(el)
+			continue
+		}
+		c.remove(el)
+		c.evictions++
+	}
+	c.items[key] = c.ll.PushFront(&clockEntry{key: key, value: value, expires: expires})
+}
+
+func (c *ClockCache) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*clockEntry).key)
+}
+
+// Clear 清空缓存, 统计数据保留
+func (c *ClockCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[CacheKey]*list.Element)
+}
+
+func (c *ClockCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return CacheStats{
+		Hits:        c.hits.Load(),
+		Misses:      c.misses.Load(),
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+		Len:         c.ll.Len(),
+	}
+}
@@ -0,0 +1,168 @@
+package ipdb_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClockCache(t *testing.T) {
+	a := ipdb.CacheKey{Offset: 1, Language: "CN"}
+	b := ipdb.CacheKey{Offset: 2, Language: "CN"}
+	c3 := ipdb.CacheKey{Offset: 1, Language: "EN"}
+
+	c := ipdb.NewClockCache(2, 0)
+	c.Set(a, 1)
+	c.Set(b, 2)
+
+	v, ok := c.Get(a)
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	// a 被访问过, 获得一次保留的机会, 未被访问的 b 被淘汰
+	c.Set(c3, 3)
+	_, ok = c.Get(b)
+	assert.False(t, ok)
+	_, ok = c.Get(c3)
+	assert.True(t, ok)
+
+	assert.Equal(t, ipdb.CacheStats{Hits: 2, Misses: 1, Evictions: 1, Len: 2}, c.Stats())
+
+	c.Clear()
+	_, ok = c.Get(a)
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Stats().Len)
+}
+
+func TestClockCacheTTL(t *testing.T) {
+	a := ipdb.CacheKey{Offset: 1, Language: "CN"}
+
+	c := ipdb.NewClockCache(10, 10*time.Millisecond)
+	c.Set(a, 1)
+	_, ok := c.Get(a)
+	assert.True(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+	_, ok = c.Get(a)
+	assert.False(t, ok)
+	assert.Equal(t, uint64(1), c.Stats().Expirations)
+}
+
+func TestCity_SetCache(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	city.SetCache(ipdb.NewClockCache(1, 0))
+	for _, ip := range []string{"1.2.3.4", "1.2.3.4", "1.1.1.1"} {
+		_, err := city.FindInfo(ip, "CN")
+		require.NoError(t, err)
+	}
+	assert.Equal(t, ipdb.CacheStats{Hits: 1, Misses: 2, Evictions: 1, Len: 1}, city.CacheStats())
+
+	city.SetCache(nil)
+	_, err = city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, ipdb.CacheStats{}, city.CacheStats())
+}
+
+func TestCity_CacheSharedRecord(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	// 同一条记录的不同写法和同一网络中的不同地址共享缓存条目
+	for _, ip := range []string{"1.2.3.4", "::ffff:1.2.3.4", "1.2.3.200"} {
+		info, err := city.FindInfo(ip, "CN")
+		require.NoError(t, err)
+		assert.Equal(t, "北京", info.CityName)
+	}
+	assert.Equal(t, ipdb.CacheStats{Hits: 2, Misses: 1, Len: 1}, city.CacheStats())
+
+	_, err = city.FindInfo("1.2.3.4", "EN")
+	require.NoError(t, err)
+	assert.Equal(t, 2, city.CacheStats().Len)
+}
+
+func TestCity_CacheFindMap(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	m, err := city.FindMap("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", m["city_name"])
+	m["city_name"] = "上海"
+
+	// 命中缓存, 修改返回的映射不影响缓存
+	m, err = city.FindMap("1.2.3.200", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", m["city_name"])
+	assert.Equal(t, ipdb.CacheStats{Hits: 1, Misses: 1, Len: 1}, city.CacheStats())
+
+	// 结构体和映射共用同一条目, 交替查询不会互相覆盖
+	info, err := city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.CityName)
+	for i := 0; i < 2; i++ {
+		_, err = city.FindMap("1.2.3.4", "CN")
+		require.NoError(t, err)
+		again, err := city.FindInfo("1.2.3.4", "CN")
+		require.NoError(t, err)
+		assert.Same(t, info, again)
+	}
+	assert.Equal(t, 1, city.CacheStats().Len)
+}
+
+func TestCity_ConcurrentReload(t *testing.T) {
+	// 两个版本的记录布局相同, 同一偏移上的内容不同
+	build := func(city string) string {
+		w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name"}, "CN")
+		require.NoError(t, err)
+		require.NoError(t, w.InsertCIDR("1.2.3.0/24", map[string][]string{"CN": {"中国", city, city}}))
+		name := filepath.Join(t.TempDir(), city+".ipdb")
+		require.NoError(t, w.Save(name))
+		return name
+	}
+	files := []string{build("北京"), build("上海")}
+
+	city, err := ipdb.NewCity(files[0])
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				info, err := city.FindInfo("1.2.3.4", "CN")
+				if assert.NoError(t, err) {
+					assert.Equal(t, info.RegionName, info.CityName)
+				}
+				assert.True(t, city.IsIPv4())
+				assert.Equal(t, []string{"CN"}, city.Languages())
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		require.NoError(t, city.Reload(files[i%2]))
+		city.ClearCache()
+		city.SetCache(ipdb.NewClockCache(16, 0))
+	}
+	close(stop)
+	wg.Wait()
+
+	// 最后一次加载的是 files[1], 缓存中不能残留旧版本的记录
+	info, err := city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "上海", info.CityName)
+}
@@ -1,13 +1,11 @@
 package ipdb
 
 import (
-	"encoding/json"
-	"fmt"
+	"context"
+	"io"
+	"io/fs"
 	"net"
-	"os"
-	"reflect"
-	"sync"
-	"time"
+	"net/netip"
 )
 
 // CityInfo is City Database Content
@@ -62,178 +60,139 @@ type ASNInfo struct {
 
 // City struct
 type City struct {
-	reader *reader
-	cache  *sync.Map    // 添加缓存
-	mu     sync.RWMutex // 用于保护并发访问
+	database
+}
+
+// newCityDB wraps the reader in a City handle, failing when required City fields are missing
+func newCityDB(r *reader) (*City, error) {
+	db := &City{}
+	if err := db.init(r, &CityInfo{}, ProductCity); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 // NewCity initialize
 func NewCity(name string) (*City, error) {
 	r, e := newReader(name, &CityInfo{})
 	if e != nil {
-		return nil, fmt.Errorf("初始化City数据库失败: %v", e)
+		return nil, msgInit.wrap(e, "City")
 	}
 
-	return &City{
-		reader: r,
-		cache:  &sync.Map{},
-	}, nil
+	return newCityDB(r)
+}
+
+// NewCityMmap initialize with a memory-mapped file, call Close to release it
+func NewCityMmap(name string) (*City, error) {
+	r, e := newReaderMmap(name, &CityInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "City")
+	}
+
+	return newCityDB(r)
 }
 
 // NewCityFromBytes initialize from bytes
 func NewCityFromBytes(bs []byte) (*City, error) {
 	r, e := newReaderFromBytes(bs, &CityInfo{})
 	if e != nil {
-		return nil, fmt.Errorf("从字节数据初始化City数据库失败: %v", e)
+		return nil, msgInitBytes.wrap(e, "City")
 	}
 
-	return &City{
-		reader: r,
-		cache:  &sync.Map{},
-	}, nil
+	return newCityDB(r)
 }
 
-// Reload the database
-func (db *City) Reload(name string) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	if _, err := os.Stat(name); err != nil {
-		return fmt.Errorf("数据库文件不存在: %v", err)
-	}
-
-	reader, err := newReader(name, &CityInfo{})
-	if err != nil {
-		return fmt.Errorf("加载数据库失败: %v", err)
+// NewCityFromReader initialize from io.Reader, maxSize limits the bytes read when greater than 0
+func NewCityFromReader(rd io.Reader, maxSize int64) (*City, error) {
+	r, e := newReaderFromReader(rd, maxSize, &CityInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "City")
 	}
 
-	db.reader = reader
-	db.ClearCache() // 清理缓存
-
-	return nil
+	return newCityDB(r)
 }
 
-// ClearCache clears the internal cache
-func (db *City) ClearCache() {
-	db.cache = &sync.Map{}
+// NewCityFromReaderAt initialize from io.ReaderAt with the given size
+func NewCityFromReaderAt(rd io.ReaderAt, size int64) (*City, error) {
+	r, e := newReaderFromReaderAt(rd, size, &CityInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "City")
+	}
+
+	return newCityDB(r)
 }
 
-// validateIP validates IP address format
-func validateIP(addr string) error {
-	if net.ParseIP(addr) == nil {
-		return fmt.Errorf("无效的IP地址格式: %s", addr)
+// NewCityFromFS initialize from a file in fs.FS, such as embed.FS
+func NewCityFromFS(fsys fs.FS, name string) (*City, error) {
+	r, e := newReaderFromFS(fsys, name, &CityInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "City")
 	}
-	return nil
+
+	return newCityDB(r)
 }
 
 // FindInfo query with addr
 func (db *City) FindInfo(addr, language string) (*CityInfo, error) {
-	// 验证IP地址
-	if err := validateIP(addr); err != nil {
-		return nil, err
-	}
-
-	// 检查缓存
-	if val, ok := db.cache.Load(addr + language); ok {
-		if info, ok := val.(*CityInfo); ok {
-			return info, nil
-		}
-	}
-
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	return db.FindInfoContext(context.Background(), addr, language)
+}
 
-	data, err := db.reader.FindMap(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
-	}
+// FindInfoContext is FindInfo with a context, returns ctx.Err() when ctx is already done
+func (db *City) FindInfoContext(ctx context.Context, addr, language string) (*CityInfo, error) {
+	info, _, err := findInfo(ctx, &db.database, addr, language, decodeCityInfo)
+	return info, err
+}
 
+// decodeCityInfo 将字段映射解析为 CityInfo
+func decodeCityInfo(r *reader, data []string) *CityInfo {
 	info := &CityInfo{}
-
-	// 使用反射优化
-	val := reflect.ValueOf(info).Elem()
-	for k, v := range data {
-		field := val.FieldByName(db.reader.refType[k])
-		if !field.IsValid() || !field.CanSet() {
-			continue
-		}
-
-		switch field.Type().String() {
-		case "[]ipdb.ASNInfo":
-			var asnList []ASNInfo
-			if err := json.Unmarshal([]byte(v), &asnList); err == nil {
-				field.Set(reflect.ValueOf(asnList))
-			}
-		case "ipdb.DistrictInfo":
-			var dist DistrictInfo
-			if err := json.Unmarshal([]byte(v), &dist); err == nil {
-				field.Set(reflect.ValueOf(dist))
-			}
-		default:
-			field.SetString(v)
-		}
-	}
-
-	// 存入缓存
-	db.cache.Store(addr+language, info)
-
-	return info, nil
+	r.fill(info, data)
+	return info
 }
 
-// Find query with addr
-func (db *City) Find(addr, language string) ([]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
-	}
-
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.find1(addr, language)
+// FindInfoWithNetwork query with addr, also returns the matched network
+func (db *City) FindInfoWithNetwork(addr, language string) (*CityInfo, *net.IPNet, error) {
+	return findInfo(context.Background(), &db.database, addr, language, decodeCityInfo)
 }
 
-// FindMap query with addr
-func (db *City) FindMap(addr, language string) (map[string]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
-	}
-
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-
-	data, err := db.reader.find1(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
-	}
+// FindInfoAddr query with netip.Addr
+func (db *City) FindInfoAddr(addr netip.Addr, language string) (*CityInfo, error) {
+	info, _, err := db.FindInfoWithPrefix(addr, language)
+	return info, err
+}
 
-	info := make(map[string]string, len(db.reader.meta.Fields))
-	for k, v := range data {
-		info[db.reader.meta.Fields[k]] = v
-	}
+// FindInfoWithPrefix query with netip.Addr, also returns the matched prefix
+func (db *City) FindInfoWithPrefix(addr netip.Addr, language string) (*CityInfo, netip.Prefix, error) {
+	return findInfoAddr(&db.database, addr, language, decodeCityInfo)
+}
 
-	return info, nil
+// FindRange returns every network inside prefix with its record, in address order.
+// When prefix lies inside a larger network, the result is prefix itself with that record.
+func (db *City) FindRange(prefix netip.Prefix, language string) ([]RangeItem[CityInfo], error) {
+	return db.FindRangeContext(context.Background(), prefix, language)
 }
 
-// IsIPv4 whether support ipv4
-func (db *City) IsIPv4() bool {
-	return db.reader.IsIPv4Support()
+// FindRangeContext is FindRange with cancellation during the subtree walk
+func (db *City) FindRangeContext(ctx context.Context, prefix netip.Prefix, language string) ([]RangeItem[CityInfo], error) {
+	return findRange(ctx, &db.database, prefix, language, decodeCityInfo)
 }
 
-// IsIPv6 whether support ipv6
-func (db *City) IsIPv6() bool {
-	return db.reader.IsIPv6Support()
+// Walk iterates over every network in the database, stops when fn returns false
+func (db *City) Walk(language string, fn func(network *net.IPNet, info *CityInfo) bool) error {
+	return db.WalkContext(context.Background(), language, fn)
 }
 
-// Languages return support languages
-func (db *City) Languages() []string {
-	return db.reader.Languages()
+// WalkContext is Walk with cancellation, returns ctx.Err() when ctx is done during the walk
+func (db *City) WalkContext(ctx context.Context, language string, fn func(network *net.IPNet, info *CityInfo) bool) error {
+	return walkInfo(ctx, &db.database, language, decodeCityInfo, fn)
 }
 
-// Fields return support fields
-func (db *City) Fields() []string {
-	return db.reader.meta.Fields
+// BatchFind looks up addresses concurrently, returns results in input order with per-item errors
+func (db *City) BatchFind(addrs []string, language string) []BatchItem[CityInfo] {
+	return db.BatchFindContext(context.Background(), addrs, language)
 }
 
-// BuildTime return database build Time
-func (db *City) BuildTime() time.Time {
-	return db.reader.Build()
+// BatchFindContext is BatchFind with cancellation, addresses not yet looked up when ctx is done fail with ctx.Err()
+func (db *City) BatchFindContext(ctx context.Context, addrs []string, language string) []BatchItem[CityInfo] {
+	return batchFind(ctx, &db.database, addrs, language, decodeCityInfo)
 }
@@ -0,0 +1,155 @@
+package ipdb
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrFieldEmpty 记录中没有该字段的值, 属于 ErrNotFound
+	ErrFieldEmpty = kindError("empty field value", "字段值为空", ErrNotFound)
+	// ErrFieldValue 记录中的字段值无法解析, 属于 ErrDatabase
+	ErrFieldValue = kindError("invalid field value", "字段值格式错误", ErrDatabase)
+)
+
+// FieldError 解析记录字段失败, Err 为 ErrFieldEmpty 或 ErrFieldValue
+type FieldError struct {
+	Field string // 字段名, 与数据库中的字段名一致
+	Value string // 原始值
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Err == ErrFieldEmpty {
+		return msgFieldEmpty.format(e.Field) + ": " + e.Err.Error()
+	}
+	return msgFieldValue.format(e.Field, e.Value) + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func fieldError(field, value string) error {
+	if value == "" {
+		return &FieldError{Field: field, Value: value, Err: ErrFieldEmpty}
+	}
+	return &FieldError{Field: field, Value: value, Err: ErrFieldValue}
+}
+
+// Coordinates 返回解析后的纬度和经度, NaN、Inf 和超出范围的值视为格式错误
+func (c *CityInfo) Coordinates() (lat, lon float64, err error) {
+	lat, err = strconv.ParseFloat(c.Latitude, 64)
+	if err != nil || !finite(lat) || lat < -90 || lat > 90 {
+		return 0, 0, fieldError("latitude", c.Latitude)
+	}
+	lon, err = strconv.ParseFloat(c.Longitude, 64)
+	if err != nil || !finite(lon) || lon < -180 || lon > 180 {
+		return 0, 0, fieldError("longitude", c.Longitude)
+	}
+	return lat, lon, nil
+}
+
+// Location 按时区名称 (如 Asia/Shanghai) 加载时区, 依赖系统或 time/tzdata 中的时区数据.
+// time.LoadLocation 把空字符串当作 UTC、把 "Local" 当作本机时区, 两者都不是数据库中的时区, 视为错误;
+// "UTC" 是有效的时区名称.
+func (c *CityInfo) Location() (*time.Location, error) {
+	if c.Timezone == "" || c.Timezone == "Local" {
+		return nil, fieldError("timezone", c.Timezone)
+	}
+	loc, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		return nil, fieldError("timezone", c.Timezone)
+	}
+	return loc, nil
+}
+
+// Offset 返回与 UTC 的时差, 支持 UTC+8、+08:00、-0530、5.5 等写法
+func (c *CityInfo) Offset() (time.Duration, error) {
+	d, ok := parseUTCOffset(c.UtcOffset)
+	if !ok {
+		return 0, fieldError("utc_offset", c.UtcOffset)
+	}
+	return d, nil
+}
+
+// IsEuropeanUnion 是否为欧盟成员国
+func (c *CityInfo) IsEuropeanUnion() (bool, error) {
+	b, ok := parseBool(c.EuropeanUnion)
+	if !ok {
+		return false, fieldError("european_union", c.EuropeanUnion)
+	}
+	return b, nil
+}
+
+// IsAnycast 是否为 Anycast 地址
+func (c *CityInfo) IsAnycast() (bool, error) {
+	b, ok := parseBool(c.Anycast)
+	if !ok {
+		return false, fieldError("anycast", c.Anycast)
+	}
+	return b, nil
+}
+
+// ASNumber 返回整数形式的 ASN, 兼容带 AS 前缀的写法
+func (c *CityInfo) ASNumber() (int, error) {
+	v := strings.TrimPrefix(strings.ToUpper(c.ASN), "AS")
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return 0, fieldError("asn", c.ASN)
+	}
+	return int(n), nil
+}
+
+// parseUTCOffset 解析时差, 可带 UTC/GMT 前缀, 单独的 UTC/GMT 表示 0
+func parseUTCOffset(v string) (time.Duration, bool) {
+	s := strings.ToUpper(strings.TrimSpace(v))
+	prefixed := false
+	for _, p := range []string{"UTC", "GMT"} {
+		if strings.HasPrefix(s, p) {
+			s = strings.TrimSpace(s[len(p):])
+			prefixed = true
+			break
+		}
+	}
+	if s == "" {
+		return 0, prefixed
+	}
+
+	sign := time.Duration(1)
+	switch s[0] {
+	case '-':
+		sign = -1
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	var hours, minutes float64
+	var err error
+	switch {
+	case strings.Contains(s, ":"):
+		parts := strings.SplitN(s, ":", 2)
+		if hours, err = strconv.ParseFloat(parts[0], 64); err == nil {
+			minutes, err = strconv.ParseFloat(parts[1], 64)
+		}
+	case len(s) == 4 && !strings.Contains(s, "."):
+		if hours, err = strconv.ParseFloat(s[:2], 64); err == nil {
+			minutes, err = strconv.ParseFloat(s[2:], 64)
+		}
+	default:
+		hours, err = strconv.ParseFloat(s, 64)
+	}
+	if err != nil || !finite(hours) || !finite(minutes) || hours < 0 || hours > 14 || minutes < 0 || minutes >= 60 {
+		return 0, false
+	}
+
+	return sign * time.Duration((hours*60+minutes)*float64(time.Minute)), true
+}
+
+// finite f 不是 NaN 也不是 ±Inf
+func finite(f float64) bool {
+	return !math.IsNaN(f) && !math.IsInf(f, 0)
+}
@@ -0,0 +1,107 @@
+package ipdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCityInfo_Accessors(t *testing.T) {
+	info := &ipdb.CityInfo{
+		Latitude:      "39.9042",
+		Longitude:     "116.4074",
+		Timezone:      "UTC",
+		UtcOffset:     "UTC+8",
+		EuropeanUnion: "0",
+		Anycast:       "1",
+		ASN:           "AS4134",
+	}
+
+	lat, lon, err := info.Coordinates()
+	require.NoError(t, err)
+	assert.Equal(t, 39.9042, lat)
+	assert.Equal(t, 116.4074, lon)
+
+	loc, err := info.Location()
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	offset, err := info.Offset()
+	require.NoError(t, err)
+	assert.Equal(t, 8*time.Hour, offset)
+
+	eu, err := info.IsEuropeanUnion()
+	require.NoError(t, err)
+	assert.False(t, eu)
+
+	anycast, err := info.IsAnycast()
+	require.NoError(t, err)
+	assert.True(t, anycast)
+
+	asn, err := info.ASNumber()
+	require.NoError(t, err)
+	assert.Equal(t, 4134, asn)
+}
+
+func TestCityInfo_Offset(t *testing.T) {
+	for v, want := range map[string]time.Duration{
+		"UTC":       0,
+		"+08:00":    8 * time.Hour,
+		"-0530":     -(5*time.Hour + 30*time.Minute),
+		"5.5":       5*time.Hour + 30*time.Minute,
+		"GMT-3":     -3 * time.Hour,
+		"UTC+05:45": 5*time.Hour + 45*time.Minute,
+	} {
+		got, err := (&ipdb.CityInfo{UtcOffset: v}).Offset()
+		if assert.NoError(t, err, v) {
+			assert.Equal(t, want, got, v)
+		}
+	}
+
+	for _, v := range []string{"", "UTC+15", "+08:75", "abc", "NaN", "+nan", "UTC-NaN", "Inf", "08:NaN", "+Inf:00"} {
+		_, err := (&ipdb.CityInfo{UtcOffset: v}).Offset()
+		assert.Error(t, err, v)
+	}
+}
+
+func TestCityInfo_Errors(t *testing.T) {
+	info := &ipdb.CityInfo{Latitude: "91", Longitude: "0", EuropeanUnion: "maybe"}
+
+	_, _, err := info.Coordinates()
+	assert.ErrorIs(t, err, ipdb.ErrFieldValue)
+	var fe *ipdb.FieldError
+	if assert.ErrorAs(t, err, &fe) {
+		assert.Equal(t, "latitude", fe.Field)
+		assert.Equal(t, "91", fe.Value)
+	}
+
+	_, err = info.Location()
+	assert.ErrorIs(t, err, ipdb.ErrFieldEmpty)
+
+	_, err = info.IsEuropeanUnion()
+	assert.ErrorIs(t, err, ipdb.ErrFieldValue)
+
+	_, err = info.IsAnycast()
+	assert.ErrorIs(t, err, ipdb.ErrFieldEmpty)
+
+	_, err = (&ipdb.CityInfo{Timezone: "Mars/Olympus"}).Location()
+	assert.ErrorIs(t, err, ipdb.ErrFieldValue)
+
+	for _, c := range [][2]string{{"NaN", "0"}, {"0", "NaN"}, {"Inf", "0"}, {"0", "-Inf"}, {"-inf", "+Infinity"}} {
+		_, _, err = (&ipdb.CityInfo{Latitude: c[0], Longitude: c[1]}).Coordinates()
+		assert.ErrorIs(t, err, ipdb.ErrFieldValue, "%v", c)
+	}
+
+	// LoadLocation 会把 "Local" 解析为本机时区, "UTC" 是有效的时区名称
+	_, err = (&ipdb.CityInfo{Timezone: "Local"}).Location()
+	assert.ErrorIs(t, err, ipdb.ErrFieldValue)
+	loc, err := (&ipdb.CityInfo{Timezone: "UTC"}).Location()
+	require.NoError(t, err)
+	assert.Equal(t, time.UTC, loc)
+
+	_, err = (&ipdb.CityInfo{ASN: "AS-x"}).ASNumber()
+	assert.ErrorIs(t, err, ipdb.ErrFieldValue)
+}
@@ -1,8 +1,12 @@
 package ipdb_test
 
 import (
+	"bytes"
 	"fmt"
+	"net/netip"
+	"os"
 	"testing"
+	"testing/fstest"
 
 	"github.com/soulteary/ipdb-go"
 	"github.com/stretchr/testify/assert"
@@ -154,3 +158,129 @@ func BenchmarkCity_FindInfo(b *testing.B) {
 		db.FindInfo("118.28.1.1", "CN")
 	}
 }
+
+func TestCity_FindInfoWithNetwork(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	assert.NoError(t, err)
+
+	tests := []struct {
+		ip      string
+		network string
+		country string
+	}{
+		{ip: "1.2.3.4", network: "1.2.3.0/24", country: "China"},
+		{ip: "::ffff:1.2.3.4", network: "1.2.3.0/24", country: "China"},
+		{ip: "1.200.0.1", network: "1.128.0.0/9", country: "Australia"},
+		{ip: "2001:db8:1::1", network: "2001:db8::/32", country: "Reserved"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			info, network, err := city.FindInfoWithNetwork(tt.ip, "EN")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.network, network.String())
+			assert.Equal(t, tt.country, info.CountryName)
+		})
+	}
+
+	_, _, err = city.FindInfoWithNetwork("invalid.ip", "EN")
+	assert.ErrorIs(t, err, ipdb.ErrIPFormat)
+}
+
+func TestCity_FindInfoWithPrefix(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	assert.NoError(t, err)
+
+	info, prefix, err := city.FindInfoWithPrefix(netip.MustParseAddr("1.2.3.4"), "EN")
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"), prefix)
+	assert.Equal(t, "China", info.CountryName)
+
+	info, prefix, err = city.FindInfoWithPrefix(netip.MustParseAddr("::ffff:1.2.3.4"), "EN")
+	assert.NoError(t, err)
+	assert.Equal(t, netip.MustParsePrefix("1.2.3.0/24"), prefix)
+	assert.Equal(t, "China", info.CountryName)
+
+	result, err := city.FindAddr(netip.MustParseAddr("2001:db8::1"), "CN")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"保留地址", "", ""}, result)
+
+	_, err = city.FindInfoAddr(netip.Addr{}, "CN")
+	assert.ErrorIs(t, err, ipdb.ErrInvalidInput)
+
+	_, err = city.FindAddr(netip.MustParseAddr("1.2.3.4"), "JP")
+	assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+}
+
+func TestCity_FindAddrMatchesFind(t *testing.T) {
+	for _, ip := range []string{"1.1.1.1", "118.28.1.1", "123.123.123.123", "8.8.8.8"} {
+		want, err := db.Find(ip, "CN")
+		assert.NoError(t, err)
+		got, err := db.FindAddr(netip.MustParseAddr(ip), "CN")
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func BenchmarkCity_FindAddr(b *testing.B) {
+	addr := netip.MustParseAddr("118.28.1.1")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		db.FindAddr(addr, "CN")
+	}
+}
+
+func TestNewCityMmap(t *testing.T) {
+	mdb, err := ipdb.NewCityMmap(TEST_DB_PATH)
+	assert.NoError(t, err)
+	defer mdb.Close()
+
+	for _, ip := range []string{"1.1.1.1", "118.28.1.1", "123.123.123.123"} {
+		want, err := db.Find(ip, "CN")
+		assert.NoError(t, err)
+		got, err := mdb.Find(ip, "CN")
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// 文件没有通过重命名替换, 拒绝重新加载正在映射的文件, 继续使用原数据库
+	assert.ErrorIs(t, mdb.Reload(TEST_DB_PATH), ipdb.ErrReloadInPlace)
+	info, err := mdb.FindInfo("118.28.1.1", "CN")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, info.CountryName)
+
+	_, err = ipdb.NewCityMmap("not_exists.ipdb")
+	assert.Error(t, err)
+}
+
+func TestNewCityFromSources(t *testing.T) {
+	body, err := os.ReadFile(TEST_DB_PATH)
+	assert.NoError(t, err)
+
+	fromReader, err := ipdb.NewCityFromReader(bytes.NewReader(body), int64(len(body)))
+	assert.NoError(t, err)
+
+	fromReaderAt, err := ipdb.NewCityFromReaderAt(bytes.NewReader(body), int64(len(body)))
+	assert.NoError(t, err)
+
+	fsys := fstest.MapFS{"data/city.ipdb": &fstest.MapFile{Data: body}}
+	fromFS, err := ipdb.NewCityFromFS(fsys, "data/city.ipdb")
+	assert.NoError(t, err)
+
+	want, err := db.Find("118.28.1.1", "CN")
+	assert.NoError(t, err)
+	for _, c := range []*ipdb.City{fromReader, fromReaderAt, fromFS} {
+		got, err := c.Find("118.28.1.1", "CN")
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	_, err = ipdb.NewCityFromReader(bytes.NewReader(body), int64(len(body))-1)
+	assert.Error(t, err)
+
+	_, err = ipdb.NewCityFromReaderAt(bytes.NewReader(body[:100]), int64(len(body)))
+	assert.Error(t, err)
+
+	_, err = ipdb.NewCityFromFS(fsys, "data/missing.ipdb")
+	assert.Error(t, err)
+}
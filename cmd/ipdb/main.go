@@ -0,0 +1,93 @@
+// ipdb 命令行工具, 用于校验和比较 ipdb 数据库文件
+//
+//	ipdb verify /path/to/city.ipdb [...]
+//	ipdb diff [-lang CN] /path/to/old.ipdb /path/to/new.ipdb
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/soulteary/ipdb-go"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "用法: ipdb verify <file>...")
+	fmt.Fprintln(os.Stderr, "      ipdb diff [-lang CN] <old> <new>")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "verify":
+		os.Exit(verify(os.Args[2:]))
+	case "diff":
+		os.Exit(diff(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+// verify 校验每个文件并以 JSON 输出报告, 任意文件存在问题时返回非 0
+func verify(files []string) int {
+	if len(files) == 0 {
+		usage()
+	}
+
+	code := 0
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	for _, name := range files {
+		report, err := ipdb.Verify(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			code = 1
+			continue
+		}
+		if !report.OK() {
+			code = 1
+		}
+		if err := enc.Encode(struct {
+			File string `json:"file"`
+			OK   bool   `json:"ok"`
+			*ipdb.VerifyReport
+		}{name, report.OK(), report}); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			code = 1
+		}
+	}
+	return code
+}
+
+// diff 比较两个数据库并以 JSON 输出差异, -lang 指定汇总使用的语言
+func diff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	lang := fs.String("lang", "", "汇总使用的语言, 默认优先使用 CN")
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage()
+	}
+
+	report, err := ipdb.Diff(fs.Arg(0), fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *lang != "" {
+		report.Summary = report.Summarize(*lang)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,133 @@
+package ipdb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// defaultMaxDecompressedSize MaxDecompressedSize 的默认值, 也是 MaxDecompressedSize 小于等于 0 时使用的上限
+const defaultMaxDecompressedSize = 1 << 30
+
+// MaxDecompressedSize 压缩数据库解压后允许的最大字节数, 防止解压炸弹.
+// 小于等于 0 时使用默认的 1GiB, 解压时不存在不受限制的情况.
+var MaxDecompressedSize int64 = defaultMaxDecompressedSize
+
+var ErrDecompressedSize = kindError("decompressed database exceeds the size limit", "解压后的IP数据库超过大小限制", ErrDatabase)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// isCompressed 根据文件头的魔数判断是否为 gzip 或 zstd 压缩数据.
+// 原始 ipdb 文件以 4 字节元数据长度开头, 不会与这两种魔数冲突.
+func isCompressed(head []byte) bool {
+	return bytes.HasPrefix(head, gzipMagic) || bytes.HasPrefix(head, zstdMagic)
+}
+
+// readDatabase 读取数据库内容, 遇到压缩数据时流式解压.
+// maxSize 大于 0 时限制最终内容的大小; 解压后的内容同时受 MaxDecompressedSize
+// 和元数据声明的文件大小限制, maxSize 小于等于 0 时也不会无限制地解压.
+func readDatabase(rd io.Reader, maxSize int64) ([]byte, error) {
+	br := bufio.NewReader(rd)
+	head, _ := br.Peek(len(zstdMagic))
+
+	var src io.Reader = br
+	compressed := true
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+		}
+		defer zr.Close()
+		src = zr
+	case bytes.HasPrefix(head, zstdMagic):
+		zr, err := zstd.NewReader(br, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+		}
+		defer zr.Close()
+		src = zr
+	default:
+		compressed = false
+	}
+	if compressed {
+		limit := MaxDecompressedSize
+		if limit <= 0 {
+			limit = defaultMaxDecompressedSize
+		}
+		if maxSize <= 0 || maxSize > limit {
+			maxSize = limit
+		}
+
+		// 解压出的内容不应超过文件头和元数据声明的大小
+		head, size, err := declaredSize(src)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+		}
+		if size > 0 && size < maxSize {
+			maxSize = size
+		}
+		src = io.MultiReader(bytes.NewReader(head), src)
+	}
+
+	if maxSize > 0 {
+		src = io.LimitReader(src, maxSize+1)
+	}
+	body, err := ioutil.ReadAll(src)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+	}
+	if maxSize > 0 && int64(len(body)) > maxSize {
+		if compressed {
+			return nil, ErrDecompressedSize
+		}
+		return nil, ErrFileSize
+	}
+
+	return body, nil
+}
+
+// maxMetaPeek declaredSize 读取元数据时允许的最大长度
+const maxMetaPeek = 1 << 20
+
+// declaredSize 预读解压后的文件头和元数据, 返回已读取的内容和元数据声明的文件总大小.
+// 元数据过长或无法解析时大小为 0, 之后由 parseBytes 报告具体错误.
+func declaredSize(rd io.Reader) ([]byte, int64, error) {
+	head := make([]byte, 4)
+	if n, err := io.ReadFull(rd, head); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return head[:n], 0, nil
+		}
+		return nil, 0, err
+	}
+	metaLength := int(binary.BigEndian.Uint32(head))
+	if metaLength > maxMetaPeek {
+		return head, 0, nil
+	}
+
+	head = append(head, make([]byte, metaLength)...)
+	if n, err := io.ReadFull(rd, head[4:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return head[:4+n], 0, nil
+		}
+		return nil, 0, err
+	}
+
+	var meta struct {
+		TotalSize int64 `json:"total_size"`
+	}
+	if json.Unmarshal(head[4:], &meta) != nil || meta.TotalSize < 0 {
+		return head, 0, nil
+	}
+	return head, int64(4+metaLength) + meta.TotalSize, nil
+}
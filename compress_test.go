@@ -0,0 +1,110 @@
+package ipdb_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gzipBytes(t *testing.T, body []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err := zw.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func zstdBytes(t *testing.T, body []byte) []byte {
+	zw, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	defer zw.Close()
+	return zw.EncodeAll(body, nil)
+}
+
+func TestCompressedDatabase(t *testing.T) {
+	body, err := os.ReadFile(TEST_DB_PATH)
+	require.NoError(t, err)
+
+	want, err := db.Find("118.28.1.1", "CN")
+	require.NoError(t, err)
+
+	for name, compressed := range map[string][]byte{
+		"gzip": gzipBytes(t, body),
+		"zstd": zstdBytes(t, body),
+	} {
+		t.Run(name, func(t *testing.T) {
+			fromBytes, err := ipdb.NewCityFromBytes(compressed)
+			require.NoError(t, err)
+			got, err := fromBytes.Find("118.28.1.1", "CN")
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			fromReader, err := ipdb.NewCityFromReader(bytes.NewReader(compressed), 0)
+			require.NoError(t, err)
+			got, err = fromReader.Find("118.28.1.1", "CN")
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			name := filepath.Join(t.TempDir(), "city.ipdb."+name)
+			require.NoError(t, os.WriteFile(name, compressed, 0644))
+
+			fromFile, err := ipdb.NewCity(name)
+			require.NoError(t, err)
+			got, err = fromFile.Find("118.28.1.1", "CN")
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+			assert.NoError(t, fromFile.Reload(name))
+
+			fromMmap, err := ipdb.NewCityMmap(name)
+			require.NoError(t, err)
+			defer fromMmap.Close()
+			got, err = fromMmap.Find("118.28.1.1", "CN")
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+
+			_, err = ipdb.NewCityFromReader(bytes.NewReader(compressed), int64(len(body))-1)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompressedDatabaseSizeLimit(t *testing.T) {
+	old := ipdb.MaxDecompressedSize
+	ipdb.MaxDecompressedSize = 1024
+	defer func() { ipdb.MaxDecompressedSize = old }()
+
+	body, err := os.ReadFile(TEST_DB_PATH)
+	require.NoError(t, err)
+
+	_, err = ipdb.NewCityFromBytes(gzipBytes(t, body))
+	assert.ErrorIs(t, err, ipdb.ErrDecompressedSize)
+}
+
+func TestCompressedDatabaseBomb(t *testing.T) {
+	// MaxDecompressedSize 小于等于 0 时仍然有上限
+	old := ipdb.MaxDecompressedSize
+	ipdb.MaxDecompressedSize = 0
+	defer func() { ipdb.MaxDecompressedSize = old }()
+
+	// 合法的文件头之后跟着大量压缩率极高的数据, 解压到元数据声明的大小即停止
+	bomb := append(buildTestDB(t), make([]byte, 16<<20)...)
+	for _, compressed := range [][]byte{gzipBytes(t, bomb), zstdBytes(t, bomb)} {
+		name := filepath.Join(t.TempDir(), "bomb.ipdb")
+		require.NoError(t, os.WriteFile(name, compressed, 0o644))
+
+		_, err := ipdb.Verify(name)
+		assert.ErrorIs(t, err, ipdb.ErrDecompressedSize)
+		_, err = ipdb.VerifyBytes(compressed)
+		assert.ErrorIs(t, err, ipdb.ErrDecompressedSize)
+		_, err = ipdb.NewCity(name)
+		assert.ErrorIs(t, err, ipdb.ErrDecompressedSize)
+	}
+}
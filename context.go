@@ -0,0 +1,62 @@
+package ipdb
+
+import (
+	"context"
+	"io"
+)
+
+// cancelCheckInterval 校验、遍历等循环中每隔多少步检查一次 ctx
+const cancelCheckInterval = 1 << 16
+
+// readChunkSize 带 ctx 读取文件时每次读取的最大字节数
+const readChunkSize = 1 << 20
+
+// ctxReader 每次读取前检查 ctx, 并限制单次读取的大小, 以便读取大文件时及时中止
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func contextReader(ctx context.Context, r io.Reader) io.Reader {
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) > readChunkSize {
+		p = p[:readChunkSize]
+	}
+	return r.r.Read(p)
+}
+
+// canceled ctx 已取消时返回 ctx 的错误, 而不是读取失败的错误
+func canceled(ctx context.Context, err error) error {
+	if e := ctx.Err(); e != nil {
+		return e
+	}
+	return err
+}
+
+// FindContext 与 Find 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *database) FindContext(ctx context.Context, addr, language string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+	return db.Find(addr, language)
+}
+
+// FindMapContext 与 FindMap 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *database) FindMapContext(ctx context.Context, addr, language string) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+	return db.FindMap(addr, language)
+}
+
+// ReloadContext 与 Reload 相同, 读取和校验新文件时响应 ctx 的取消, 取消后继续使用原数据库
+func (db *database) ReloadContext(ctx context.Context, name string) error {
+	_, err := db.ReloadWithOptions(ctx, name, ReloadOptions{})
+	return err
+}
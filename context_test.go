@@ -0,0 +1,83 @@
+package ipdb_test
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContext_Find(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	info, err := city.FindInfoContext(context.Background(), "1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.CityName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = city.FindInfoContext(ctx, "1.2.3.4", "CN")
+	assert.ErrorIs(t, err, context.Canceled)
+	var le *ipdb.LookupError
+	require.ErrorAs(t, err, &le)
+	assert.Equal(t, "1.2.3.4", le.IP)
+
+	_, err = city.FindContext(ctx, "1.2.3.4", "CN")
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = city.FindMapContext(ctx, "1.2.3.4", "CN")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	bs, err := ipdb.NewBaseStationFromBytes(buildProductDB(t, productFields[ipdb.ProductBaseStation]...))
+	require.NoError(t, err)
+	results := bs.BatchFindContext(ctx, []string{"1.2.3.4", "2001:db8::1"}, "CN")
+	require.Len(t, results, 2)
+	for _, r := range results {
+		assert.ErrorIs(t, r.Error, context.Canceled)
+		assert.Nil(t, r.Info)
+	}
+}
+
+func TestContext_WalkCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	count := 0
+	err := db.WalkContext(ctx, "CN", func(network *net.IPNet, info *ipdb.CityInfo) bool {
+		count++
+		if count == 10 {
+			cancel()
+		}
+		return true
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 10, count)
+}
+
+func TestContext_ReloadCancel(t *testing.T) {
+	body, err := os.ReadFile(TEST_DB_PATH)
+	require.NoError(t, err)
+	name := filepath.Join(t.TempDir(), "city.ipdb")
+	require.NoError(t, os.WriteFile(name, buildTestDB(t), 0o644))
+
+	city, err := ipdb.NewCity(name)
+	require.NoError(t, err)
+
+	// 取消后继续使用原数据库
+	require.NoError(t, os.WriteFile(name, body, 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = city.ReloadContext(ctx, name)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ipdb.ErrDatabase)
+	assert.Equal(t, int64(1700000000), city.BuildTime().Unix())
+
+	require.NoError(t, city.ReloadContext(context.Background(), name))
+	assert.NotEqual(t, int64(1700000000), city.BuildTime().Unix())
+}
@@ -0,0 +1,227 @@
+package ipdb
+
+import (
+	"context"
+	"maps"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Database 各类数据库共有的查询和元数据方法, 可用于接收任意类型的数据库, 或在测试中替换实现
+type Database interface {
+	Find(addr, language string) ([]string, error)
+	FindMap(addr, language string) (map[string]string, error)
+	FindAddr(addr netip.Addr, language string) ([]string, error)
+	FindContext(ctx context.Context, addr, language string) ([]string, error)
+	FindMapContext(ctx context.Context, addr, language string) (map[string]string, error)
+
+	IsIPv4() bool
+	IsIPv6() bool
+	Languages() []string
+	Fields() []string
+	BuildTime() time.Time
+}
+
+// Reloader 重新加载、回滚和释放数据库文件, 各类数据库都实现了该接口
+type Reloader interface {
+	Reload(name string) error
+	ReloadContext(ctx context.Context, name string) error
+	ReloadWithOptions(ctx context.Context, name string, opts ReloadOptions) (ReloadReport, error)
+	Rollback() (ReloadReport, error)
+	Close() error
+}
+
+// Cacher 管理查询缓存, 各类数据库都实现了该接口
+type Cacher interface {
+	ClearCache()
+	SetCache(c Cache)
+	CacheStats() CacheStats
+}
+
+var (
+	_ Database = (*City)(nil)
+	_ Database = (*District)(nil)
+	_ Database = (*IDC)(nil)
+	_ Database = (*BaseStation)(nil)
+	_ Database = (*Risk)(nil)
+	_ Database = (*Typed[struct{}])(nil)
+
+	_ Reloader = (*database)(nil)
+	_ Cacher   = (*database)(nil)
+)
+
+// database Database、Reloader 和 Cacher 的公共实现, 嵌入到各类数据库中
+type database struct {
+	snap    atomic.Pointer[snapshot] // 当前快照, 查询时原子读取, 不获取 mu
+	prev    *snapshot                // 最近一次重新加载前的快照, 用于 Rollback, 由 mu 保护
+	mu      sync.Mutex               // 串行化 Reload、Rollback、SetCache、Close
+	closed  bool                     // 已调用 Close, 由 mu 保护
+	obj     interface{}              // 记录对应的结构体, Reload 时用于建立字段索引
+	product Product                  // 期望的产品类型, 为空时不检查字段
+}
+
+// init 检查 reader 是否包含期望产品的必需字段并创建初始快照, 缺少时释放 reader
+func (db *database) init(r *reader, obj interface{}, product Product) error {
+	if err := checkProduct(r, product); err != nil {
+		r.close()
+		return err
+	}
+	if r.fieldIndex == nil {
+		r.fieldIndex = fieldIndex(obj, r.meta.Fields)
+	}
+
+	db.obj = obj
+	db.product = product
+	db.snap.Store(newSnapshot(r, newCache(), product))
+	return nil
+}
+
+// validateIP validates IP address format
+func validateIP(addr string) error {
+	if net.ParseIP(addr) == nil {
+		return ErrIPFormat
+	}
+	return nil
+}
+
+// acquire 取得当前快照并登记为其 reader 的使用者, 使用完毕后调用 s.reader.release.
+// 取到的 reader 恰好被重新加载替换时重新读取快照, 数据库已关闭时返回 ErrClosed.
+func (db *database) acquire() (*snapshot, error) {
+	for {
+		s := db.snap.Load()
+		if s.reader.acquire() {
+			return s, nil
+		}
+		if db.snap.Load() == s {
+			return nil, ErrClosed
+		}
+	}
+}
+
+// Find 查询IP地址, 按字段顺序返回指定语言的值
+func (db *database) Find(addr, language string) ([]string, error) {
+	if err := validateIP(addr); err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+
+	s, err := db.acquire()
+	if err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+	defer s.reader.release()
+
+	data, err := s.reader.find1(addr, language)
+	if err != nil {
+		return nil, s.lookupError(addr, language, err)
+	}
+	return data, nil
+}
+
+// FindMap 查询IP地址, 返回字段名到值的映射
+func (db *database) FindMap(addr, language string) (map[string]string, error) {
+	if err := validateIP(addr); err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+
+	s, err := db.acquire()
+	if err != nil {
+		return nil, db.lookupError(addr, language, err)
+	}
+	defer s.reader.release()
+
+	node, _, err := s.reader.locate(addr)
+	if err != nil {
+		return nil, s.lookupError(addr, language, err)
+	}
+	m, err := cachedInfo(s, node, language, decodeMap)
+	if err != nil {
+		return nil, s.lookupError(addr, language, err)
+	}
+
+	// 缓存中的映射被多个查询共享, 返回副本以免调用方修改
+	return maps.Clone(*m), nil
+}
+
+// FindAddr 使用 netip.Addr 查询, 避免再次解析地址
+func (db *database) FindAddr(addr netip.Addr, language string) ([]string, error) {
+	s, err := db.acquire()
+	if err != nil {
+		return nil, db.lookupError(addr.String(), language, err)
+	}
+	defer s.reader.release()
+
+	data, _, err := s.reader.findAddr(addr, language)
+	if err != nil {
+		return nil, s.lookupError(addr.String(), language, err)
+	}
+	return data, nil
+}
+
+// IsIPv4 是否支持 IPv4
+func (db *database) IsIPv4() bool {
+	return db.snap.Load().reader.IsIPv4Support()
+}
+
+// IsIPv6 是否支持 IPv6
+func (db *database) IsIPv6() bool {
+	return db.snap.Load().reader.IsIPv6Support()
+}
+
+// Languages 返回支持的语言
+func (db *database) Languages() []string {
+	return db.snap.Load().reader.Languages()
+}
+
+// Fields 返回支持的字段
+func (db *database) Fields() []string {
+	return db.snap.Load().reader.meta.Fields
+}
+
+// BuildTime 返回数据库构建时间
+func (db *database) BuildTime() time.Time {
+	return db.snap.Load().reader.Build()
+}
+
+// Reload 重新加载数据库文件, 沿用当前的加载方式 (堆内存或 mmap) 和缓存.
+// 新数据库加载并校验通过后才原子替换, 失败时继续使用原数据库, 被替换的数据库可以用 Rollback 恢复.
+func (db *database) Reload(name string) error {
+	return db.ReloadContext(context.Background(), name)
+}
+
+// Close 关闭数据库, 之后的查询返回 ErrClosed (属于 ErrDatabase), Reload 同样返回 ErrClosed.
+// 正在进行的查询、遍历和批量查询不受影响, mmap 映射在它们全部结束后才释放,
+// 此时 Close 返回 nil, 释放映射的错误不再报告.
+func (db *database) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.closed = true
+	if db.prev != nil {
+		db.prev.reader.retire()
+		db.prev = nil
+	}
+	return db.snap.Load().reader.retire()
+}
+
+// ClearCache 清理查询缓存
+func (db *database) ClearCache() {
+	db.snap.Load().cache.Clear()
+}
+
+// SetCache 替换查询缓存, 传入 nil 关闭缓存
+func (db *database) SetCache(c Cache) {
+	if c == nil {
+		c = noCache{}
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	s := db.snap.Load()
+	db.snap.Store(&snapshot{reader: s.reader, cache: c, product: s.product})
+}
+
+// CacheStats 返回查询缓存的统计信息
+func (db *database) CacheStats() CacheStats {
+	return db.snap.Load().cache.Stats()
+}
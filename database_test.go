@@ -0,0 +1,96 @@
+package ipdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// buildProductDB 生成包含指定字段的数据库, 1.2.3.0/24 的每个值为 "语言:字段名"
+func buildProductDB(t testing.TB, fields ...string) []byte {
+	w, err := ipdb.NewWriter(fields, "CN", "EN")
+	require.NoError(t, err)
+
+	values := make(map[string][]string)
+	for _, lang := range []string{"CN", "EN"} {
+		for _, f := range fields {
+			values[lang] = append(values[lang], lang+":"+f)
+		}
+	}
+	require.NoError(t, w.InsertCIDR("1.2.3.0/24", values))
+	require.NoError(t, w.InsertCIDR("2001:db8::/32", values))
+
+	body, err := w.Bytes()
+	require.NoError(t, err)
+	return body
+}
+
+var productFields = map[ipdb.Product][]string{
+	ipdb.ProductCity:        {"country_name", "region_name", "city_name", "isp_domain", "timezone"},
+	ipdb.ProductDistrict:    {"country_name", "region_name", "city_name", "district_name", "covering_radius"},
+	ipdb.ProductIDC:         {"country_name", "region_name", "city_name", "owner_domain", "isp_domain", "idc"},
+	ipdb.ProductBaseStation: {"country_name", "region_name", "city_name", "owner_domain", "isp_domain", "base_station"},
+	ipdb.ProductRisk:        {"score", "behavior", "country_code"},
+	ipdb.ProductASN:         {"asn", "reg", "cc", "net", "org"},
+	ipdb.ProductCustom:      {"country_name", "weather"},
+}
+
+func TestDatabase(t *testing.T) {
+	open := map[ipdb.Product]func([]byte) (ipdb.Database, error){
+		ipdb.ProductCity:        func(b []byte) (ipdb.Database, error) { return ipdb.NewCityFromBytes(b) },
+		ipdb.ProductDistrict:    func(b []byte) (ipdb.Database, error) { return ipdb.NewDistrictFromBytes(b) },
+		ipdb.ProductIDC:         func(b []byte) (ipdb.Database, error) { return ipdb.NewIDCFromBytes(b) },
+		ipdb.ProductBaseStation: func(b []byte) (ipdb.Database, error) { return ipdb.NewBaseStationFromBytes(b) },
+		ipdb.ProductRisk:        func(b []byte) (ipdb.Database, error) { return ipdb.NewRiskFromBytes(b) },
+	}
+	for product, fn := range open {
+		t.Run(string(product), func(t *testing.T) {
+			fields := productFields[product]
+			body := buildProductDB(t, fields...)
+			name := filepath.Join(t.TempDir(), "test.ipdb")
+			require.NoError(t, os.WriteFile(name, body, 0o644))
+
+			d, err := fn(body)
+			require.NoError(t, err)
+
+			assert.True(t, d.IsIPv4())
+			assert.True(t, d.IsIPv6())
+			assert.ElementsMatch(t, []string{"CN", "EN"}, d.Languages())
+			assert.Equal(t, fields, d.Fields())
+			assert.False(t, d.BuildTime().IsZero())
+
+			data, err := d.Find("1.2.3.4", "EN")
+			require.NoError(t, err)
+			assert.Equal(t, "EN:"+fields[0], data[0])
+
+			m, err := d.FindMap("2001:db8::1", "CN")
+			require.NoError(t, err)
+			assert.Equal(t, "CN:"+fields[1], m[fields[1]])
+
+			_, err = d.Find("not-an-ip", "CN")
+			assert.ErrorIs(t, err, ipdb.ErrIPFormat)
+			_, err = d.FindMap("1.2.3.4", "JP")
+			assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+			_, err = d.Find("8.8.8.8", "CN")
+			assert.ErrorIs(t, err, ipdb.ErrNotFound)
+
+			rl, ok := d.(ipdb.Reloader)
+			require.True(t, ok)
+			require.NoError(t, rl.Reload(name))
+			assert.Error(t, rl.Reload(filepath.Join(t.TempDir(), "missing.ipdb")))
+			data, err = d.Find("1.2.3.4", "CN")
+			require.NoError(t, err)
+			assert.Equal(t, "CN:"+fields[0], data[0])
+
+			c, ok := d.(ipdb.Cacher)
+			require.True(t, ok)
+			c.SetCache(nil)
+			assert.Equal(t, ipdb.CacheStats{}, c.CacheStats())
+			assert.NoError(t, rl.Close())
+		})
+	}
+}
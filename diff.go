@@ -0,0 +1,314 @@
+package ipdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+)
+
+// DiffKind 网络变化的类型
+type DiffKind string
+
+const (
+	DiffAdded   DiffKind = "added"
+	DiffRemoved DiffKind = "removed"
+	DiffChanged DiffKind = "changed"
+)
+
+// DiffEntry 描述一个网络在两个版本之间的变化, Old/New 以语言、字段名为键
+type DiffEntry struct {
+	Network *net.IPNet                   `json:"network"`
+	Kind    DiffKind                     `json:"kind"`
+	Old     map[string]map[string]string `json:"old,omitempty"`
+	New     map[string]map[string]string `json:"new,omitempty"`
+}
+
+// MarshalJSON 以 CIDR 字符串输出网络
+func (e DiffEntry) MarshalJSON() ([]byte, error) {
+	type entry DiffEntry
+	return json.Marshal(struct {
+		Network string `json:"network"`
+		entry
+	}{e.Network.String(), entry(e)})
+}
+
+// DiffCounts 各类变化的数量
+type DiffCounts struct {
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	Changed int `json:"changed"`
+}
+
+func (c *DiffCounts) add(kind DiffKind) {
+	switch kind {
+	case DiffAdded:
+		c.Added++
+	case DiffRemoved:
+		c.Removed++
+	case DiffChanged:
+		c.Changed++
+	}
+}
+
+// DiffSummary 按国家、地区、运营商汇总的变化数量
+type DiffSummary struct {
+	Language  string                 `json:"language"`
+	Total     DiffCounts             `json:"total"`
+	ByCountry map[string]*DiffCounts `json:"by_country"`
+	ByRegion  map[string]*DiffCounts `json:"by_region"`
+	ByISP     map[string]*DiffCounts `json:"by_isp"`
+}
+
+// DiffReport 两个数据库版本之间的差异
+type DiffReport struct {
+	OldBuild time.Time    `json:"old_build"`
+	NewBuild time.Time    `json:"new_build"`
+	Entries  []DiffEntry  `json:"entries"`
+	Summary  *DiffSummary `json:"summary"`
+}
+
+// Summarize 使用指定语言的字段值汇总变化, 新增和修改按新值计入, 删除按旧值计入
+func (r *DiffReport) Summarize(language string) *DiffSummary {
+	s := &DiffSummary{
+		Language:  language,
+		ByCountry: make(map[string]*DiffCounts),
+		ByRegion:  make(map[string]*DiffCounts),
+		ByISP:     make(map[string]*DiffCounts),
+	}
+
+	count := func(m map[string]*DiffCounts, key string, kind DiffKind) {
+		c, ok := m[key]
+		if !ok {
+			c = &DiffCounts{}
+			m[key] = c
+		}
+		c.add(kind)
+	}
+
+	for _, e := range r.Entries {
+		values := e.New[language]
+		if e.Kind == DiffRemoved {
+			values = e.Old[language]
+		}
+
+		s.Total.add(e.Kind)
+		count(s.ByCountry, values["country_name"], e.Kind)
+		count(s.ByRegion, values["country_name"]+"/"+values["region_name"], e.Kind)
+		count(s.ByISP, values["isp_domain"], e.Kind)
+	}
+
+	return s
+}
+
+// Diff 比较两个数据库文件, 报告新增、删除和变化的网络
+func Diff(oldName, newName string) (*DiffReport, error) {
+	a, err := newReader(oldName, nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newReader(newName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return diffReaders(a, b), nil
+}
+
+// DiffBytes 比较内存中的两个数据库
+func DiffBytes(oldBody, newBody []byte) (*DiffReport, error) {
+	a, err := newReaderFromBytes(oldBody, nil)
+	if err != nil {
+		return nil, err
+	}
+	b, err := newReaderFromBytes(newBody, nil)
+	if err != nil {
+		return nil, err
+	}
+	return diffReaders(a, b), nil
+}
+
+// diffSide 一侧数据库及已解析记录的缓存
+type diffSide struct {
+	db      *reader
+	records map[int]map[string]map[string]string
+}
+
+// values 解析叶子节点在所有语言下的字段值, 空节点返回 nil
+func (s *diffSide) values(node int) map[string]map[string]string {
+	if node <= s.db.nodeCount {
+		return nil
+	}
+	if v, ok := s.records[node]; ok {
+		return v
+	}
+
+	v := make(map[string]map[string]string, len(s.db.meta.Languages))
+	for lang := range s.db.meta.Languages {
+		if data, err := s.db.record(node, lang); err == nil {
+			v[lang] = s.db.toMap(data)
+		}
+	}
+	s.records[node] = v
+	return v
+}
+
+func diffReaders(a, b *reader) *DiffReport {
+	d := &differ{
+		old: &diffSide{db: a, records: make(map[int]map[string]map[string]string)},
+		new: &diffSide{db: b, records: make(map[int]map[string]map[string]string)},
+
+		sameLayout: sameLayout(a, b),
+	}
+
+	if a.IsIPv4Support() || b.IsIPv4Support() {
+		d.walk(d.start(a, true), d.start(b, true), make(net.IP, net.IPv4len), 0, 32)
+	}
+	if a.IsIPv6Support() || b.IsIPv6Support() {
+		d.skipIPv4 = a.IsIPv4Support() || b.IsIPv4Support()
+		d.walk(d.start(a, false), d.start(b, false), make(net.IP, net.IPv6len), 0, 128)
+	}
+
+	report := &DiffReport{
+		OldBuild: a.Build(),
+		NewBuild: b.Build(),
+		Entries:  d.entries,
+	}
+
+	language := "CN"
+	if _, ok := b.meta.Languages[language]; !ok {
+		ls := b.Languages()
+		sort.Strings(ls)
+		language = ls[0]
+	}
+	report.Summary = report.Summarize(language)
+
+	return report
+}
+
+type differ struct {
+	old, new   *diffSide
+	sameLayout bool
+	skipIPv4   bool
+	entries    []DiffEntry
+}
+
+// start 返回遍历的起始节点, 不支持对应 IP 版本的数据库视为空
+func (d *differ) start(db *reader, v4 bool) int {
+	if v4 {
+		if !db.IsIPv4Support() {
+			return db.nodeCount
+		}
+		return db.v4offset
+	}
+	if !db.IsIPv6Support() {
+		return db.nodeCount
+	}
+	return 0
+}
+
+// walk 同时遍历两棵树. 一侧已经是叶子而另一侧继续分裂时, 叶子覆盖两个子网络.
+func (d *differ) walk(na, nb int, ip net.IP, depth, bits int) {
+	ca, cb := d.old.db.nodeCount, d.new.db.nodeCount
+	internalA, internalB := na < ca, nb < cb
+
+	if depth >= bits || (!internalA && !internalB) {
+		if internalA {
+			na = ca
+		}
+		if internalB {
+			nb = cb
+		}
+		d.compare(na, nb, ip, depth, bits)
+		return
+	}
+	if d.skipIPv4 && bits == 128 && depth == 96 && isIPv4Path(ip) {
+		return
+	}
+
+	mask := byte(1) << (7 - uint(depth&7))
+	for bit := 0; bit < 2; bit++ {
+		if bit == 1 {
+			ip[depth>>3] |= mask
+		}
+		ra, rb := na, nb
+		if internalA {
+			ra = d.old.db.readNode(na, bit)
+		}
+		if internalB {
+			rb = d.new.db.readNode(nb, bit)
+		}
+		d.walk(ra, rb, ip, depth+1, bits)
+	}
+	ip[depth>>3] &^= mask
+}
+
+func (d *differ) compare(na, nb int, ip net.IP, depth, bits int) {
+	ea, eb := na <= d.old.db.nodeCount, nb <= d.new.db.nodeCount
+	if ea && eb {
+		return
+	}
+
+	// 两侧记录内容完全相同且元数据一致时无需解析
+	if !ea && !eb && d.sameLayout {
+		ra, errA := d.old.db.resolve(na)
+		rb, errB := d.new.db.resolve(nb)
+		if errA == nil && errB == nil && bytes.Equal(ra, rb) {
+			return
+		}
+	}
+
+	entry := DiffEntry{
+		Network: &net.IPNet{IP: append(net.IP(nil), ip...), Mask: net.CIDRMask(depth, bits)},
+		Old:     d.old.values(na),
+		New:     d.new.values(nb),
+	}
+	switch {
+	case ea:
+		entry.Kind = DiffAdded
+	case eb:
+		entry.Kind = DiffRemoved
+	default:
+		if equalValues(entry.Old, entry.New) {
+			return
+		}
+		entry.Kind = DiffChanged
+	}
+	d.entries = append(d.entries, entry)
+}
+
+// sameLayout 两个数据库的语言和字段排列是否一致
+func sameLayout(a, b *reader) bool {
+	if len(a.meta.Fields) != len(b.meta.Fields) || len(a.meta.Languages) != len(b.meta.Languages) {
+		return false
+	}
+	for i, f := range a.meta.Fields {
+		if b.meta.Fields[i] != f {
+			return false
+		}
+	}
+	for lang, off := range a.meta.Languages {
+		if o, ok := b.meta.Languages[lang]; !ok || o != off {
+			return false
+		}
+	}
+	return true
+}
+
+func equalValues(a, b map[string]map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for lang, fa := range a {
+		fb, ok := b[lang]
+		if !ok || len(fa) != len(fb) {
+			return false
+		}
+		for k, v := range fa {
+			if w, ok := fb[k]; !ok || w != v {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,67 @@
+package ipdb_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffBytes(t *testing.T) {
+	old := buildTestDB(t)
+
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name"}, "CN", "EN")
+	require.NoError(t, err)
+	require.NoError(t, w.InsertCIDR("1.0.0.0/8", map[string][]string{
+		"CN": {"澳大利亚", "", ""},
+		"EN": {"Australia", "", ""},
+	}))
+	require.NoError(t, w.InsertCIDR("1.2.3.0/24", map[string][]string{
+		"CN": {"中国", "上海", "上海"},
+		"EN": {"China", "Shanghai", "Shanghai"},
+	}))
+	require.NoError(t, w.InsertCIDR("8.8.8.0/24", map[string][]string{
+		"CN": {"美国", "", ""},
+		"EN": {"United States", "", ""},
+	}))
+	updated, err := w.Bytes()
+	require.NoError(t, err)
+
+	report, err := ipdb.DiffBytes(old, updated)
+	require.NoError(t, err)
+
+	kinds := make(map[string]ipdb.DiffKind)
+	for _, e := range report.Entries {
+		kinds[e.Network.String()] = e.Kind
+	}
+	assert.Equal(t, map[string]ipdb.DiffKind{
+		"1.2.3.0/24":    ipdb.DiffChanged,
+		"8.8.8.0/24":    ipdb.DiffAdded,
+		"2001:db8::/32": ipdb.DiffRemoved,
+	}, kinds)
+
+	for _, e := range report.Entries {
+		if e.Kind == ipdb.DiffChanged {
+			assert.Equal(t, "Beijing", e.Old["EN"]["city_name"])
+			assert.Equal(t, "Shanghai", e.New["EN"]["city_name"])
+		}
+	}
+
+	assert.Equal(t, "CN", report.Summary.Language)
+	assert.Equal(t, ipdb.DiffCounts{Added: 1, Removed: 1, Changed: 1}, report.Summary.Total)
+	assert.Equal(t, 1, report.Summary.ByCountry["中国"].Changed)
+	assert.Equal(t, 1, report.Summary.ByRegion["中国/上海"].Changed)
+	assert.Equal(t, 1, report.Summarize("EN").ByCountry["United States"].Added)
+
+	out, err := json.Marshal(report)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), `"network":"1.2.3.0/24"`)
+}
+
+func TestDiffSame(t *testing.T) {
+	report, err := ipdb.Diff(TEST_DB_PATH, TEST_DB_PATH)
+	require.NoError(t, err)
+	assert.Empty(t, report.Entries)
+}
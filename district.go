@@ -1,11 +1,11 @@
 package ipdb
 
 import (
-	"fmt"
-	"os"
-	"reflect"
-	"sync"
-	"time"
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
 )
 
 type DistrictInfo struct {
@@ -20,142 +20,137 @@ type DistrictInfo struct {
 }
 
 type District struct {
-	reader *reader
-	cache  *sync.Map    // 添加缓存
-	mu     sync.RWMutex // 用于保护并发访问
+	database
+}
+
+// newDistrictDB 使用 reader 创建数据库实例
+func newDistrictDB(r *reader) (*District, error) {
+	db := &District{}
+	if err := db.init(r, &DistrictInfo{}, ProductDistrict); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 func NewDistrict(name string) (*District, error) {
 	r, e := newReader(name, &DistrictInfo{})
 	if e != nil {
-		return nil, fmt.Errorf("初始化District数据库失败: %v", e)
+		return nil, msgInit.wrap(e, "District")
 	}
 
-	return &District{
-		reader: r,
-		cache:  &sync.Map{},
-	}, nil
+	return newDistrictDB(r)
 }
 
-func (db *District) Reload(name string) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	if _, err := os.Stat(name); err != nil {
-		return fmt.Errorf("数据库文件不存在: %v", err)
-	}
-
-	reader, err := newReader(name, &DistrictInfo{})
-	if err != nil {
-		return fmt.Errorf("加载数据库失败: %v", err)
+// NewDistrictMmap 通过 mmap 加载数据库, 使用完毕后调用 Close 释放
+func NewDistrictMmap(name string) (*District, error) {
+	r, e := newReaderMmap(name, &DistrictInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "District")
 	}
 
-	db.reader = reader
-	db.ClearCache() // 清理缓存
-
-	return nil
+	return newDistrictDB(r)
 }
 
-// ClearCache 清理缓存
-func (db *District) ClearCache() {
-	db.cache = &sync.Map{}
-}
-
-func (db *District) Find(addr, language string) ([]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewDistrictFromBytes 从字节数据初始化
+func NewDistrictFromBytes(bs []byte) (*District, error) {
+	r, e := newReaderFromBytes(bs, &DistrictInfo{})
+	if e != nil {
+		return nil, msgInitBytes.wrap(e, "District")
 	}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.find1(addr, language)
+	return newDistrictDB(r)
 }
 
-func (db *District) FindMap(addr, language string) (map[string]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewDistrictFromReader 从 io.Reader 初始化, maxSize 大于 0 时限制读取的最大字节数
+func NewDistrictFromReader(rd io.Reader, maxSize int64) (*District, error) {
+	r, e := newReaderFromReader(rd, maxSize, &DistrictInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "District")
 	}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	return newDistrictDB(r)
+}
 
-	data, err := db.reader.find1(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
+// NewDistrictFromReaderAt 从 io.ReaderAt 读取 size 字节初始化
+func NewDistrictFromReaderAt(rd io.ReaderAt, size int64) (*District, error) {
+	r, e := newReaderFromReaderAt(rd, size, &DistrictInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "District")
 	}
 
-	info := make(map[string]string, len(db.reader.meta.Fields))
-	for k, v := range data {
-		info[db.reader.meta.Fields[k]] = v
+	return newDistrictDB(r)
+}
+
+// NewDistrictFromFS 从 fs.FS 中的文件初始化, 可用于 embed.FS
+func NewDistrictFromFS(fsys fs.FS, name string) (*District, error) {
+	r, e := newReaderFromFS(fsys, name, &DistrictInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "District")
 	}
 
-	return info, nil
+	return newDistrictDB(r)
 }
 
 func (db *District) FindInfo(addr, language string) (*DistrictInfo, error) {
-	// 验证IP地址
-	if err := validateIP(addr); err != nil {
-		return nil, err
-	}
+	return db.FindInfoContext(context.Background(), addr, language)
+}
 
-	// 检查缓存
-	if val, ok := db.cache.Load(addr + language); ok {
-		if info, ok := val.(*DistrictInfo); ok {
-			return info, nil
-		}
-	}
+// FindInfoContext 与 FindInfo 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *District) FindInfoContext(ctx context.Context, addr, language string) (*DistrictInfo, error) {
+	info, _, err := findInfo(ctx, &db.database, addr, language, decodeDistrictInfo)
+	return info, err
+}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+// decodeDistrictInfo 将字段映射解析为 DistrictInfo
+func decodeDistrictInfo(r *reader, data []string) *DistrictInfo {
+	info := &DistrictInfo{}
+	r.fill(info, data)
+	return info
+}
 
-	data, err := db.reader.FindMap(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
-	}
+// FindInfoWithNetwork 查找IP信息, 同时返回命中的网络
+func (db *District) FindInfoWithNetwork(addr, language string) (*DistrictInfo, *net.IPNet, error) {
+	return findInfo(context.Background(), &db.database, addr, language, decodeDistrictInfo)
+}
 
-	info := &DistrictInfo{}
-	val := reflect.ValueOf(info).Elem()
-
-	for k, v := range data {
-		field := val.FieldByName(db.reader.refType[k])
-		if !field.IsValid() || !field.CanSet() {
-			continue
-		}
-		field.SetString(v)
-	}
+// FindInfoAddr 使用 netip.Addr 查找IP信息
+func (db *District) FindInfoAddr(addr netip.Addr, language string) (*DistrictInfo, error) {
+	info, _, err := db.FindInfoWithPrefix(addr, language)
+	return info, err
+}
 
-	// 存入缓存
-	db.cache.Store(addr+language, info)
+// FindInfoWithPrefix 使用 netip.Addr 查找IP信息, 同时返回命中的网络前缀
+func (db *District) FindInfoWithPrefix(addr netip.Addr, language string) (*DistrictInfo, netip.Prefix, error) {
+	return findInfoAddr(&db.database, addr, language, decodeDistrictInfo)
+}
 
-	return info, nil
+// FindRange 返回 prefix 范围内的每个网络及其记录, 按地址顺序排列.
+// prefix 落在一个更大的网络中时, 返回 prefix 本身及该网络的记录.
+func (db *District) FindRange(prefix netip.Prefix, language string) ([]RangeItem[DistrictInfo], error) {
+	return db.FindRangeContext(context.Background(), prefix, language)
 }
 
-func (db *District) IsIPv4() bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.IsIPv4Support()
+// FindRangeContext 与 FindRange 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (db *District) FindRangeContext(ctx context.Context, prefix netip.Prefix, language string) ([]RangeItem[DistrictInfo], error) {
+	return findRange(ctx, &db.database, prefix, language, decodeDistrictInfo)
 }
 
-func (db *District) IsIPv6() bool {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.IsIPv6Support()
+// Walk 遍历数据库中的每个网络及其记录, fn 返回 false 时停止遍历
+func (db *District) Walk(language string, fn func(network *net.IPNet, info *DistrictInfo) bool) error {
+	return db.WalkContext(context.Background(), language, fn)
 }
 
-func (db *District) Languages() []string {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.Languages()
+// WalkContext 与 Walk 相同, 遍历过程中 ctx 取消时停止并返回 ctx 的错误
+func (db *District) WalkContext(ctx context.Context, language string, fn func(network *net.IPNet, info *DistrictInfo) bool) error {
+	return walkInfo(ctx, &db.database, language, decodeDistrictInfo, fn)
 }
 
-func (db *District) Fields() []string {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.meta.Fields
+// BatchFind 并发查询多个地址, 按输入顺序返回每个地址的结果和错误, 所有地址使用同一个数据库快照
+func (db *District) BatchFind(addrs []string, language string) []BatchItem[DistrictInfo] {
+	return db.BatchFindContext(context.Background(), addrs, language)
 }
 
-func (db *District) BuildTime() time.Time {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.Build()
+// BatchFindContext 与 BatchFind 相同, ctx 取消后尚未查询的地址返回 ctx 的错误
+func (db *District) BatchFindContext(ctx context.Context, addrs []string, language string) []BatchItem[DistrictInfo] {
+	return batchFind(ctx, &db.database, addrs, language, decodeDistrictInfo)
 }
@@ -2,11 +2,12 @@ package ipdb
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -24,7 +25,7 @@ type ProgressFunc func(current, total int64)
 func NewDownload(httpUrl string) (*Download, error) {
 	v, err := url.Parse(httpUrl)
 	if err != nil {
-		return nil, fmt.Errorf("解析URL失败: %v", err)
+		return nil, msgParseURL.wrap(err)
 	}
 
 	return &Download{
@@ -35,7 +36,7 @@ func NewDownload(httpUrl string) (*Download, error) {
 	}, nil
 }
 
-// SaveToFile 将URL指向的文件下载到指定路径
+// SaveToFile 将URL指向的文件下载到指定路径, 下载完成后通过重命名原子替换目标文件
 func (dl *Download) SaveToFile(fn string, progress ProgressFunc) error {
 	// 创建上下文用于超时控制
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
@@ -44,26 +45,28 @@ func (dl *Download) SaveToFile(fn string, progress ProgressFunc) error {
 	// 创建请求
 	req, err := http.NewRequestWithContext(ctx, "GET", dl.URL.String(), nil)
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
+		return msgNewRequest.wrap(err)
 	}
 
 	// 发送请求
 	resp, err := dl.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %v", err)
+		return msgSendRequest.wrap(err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("服务器返回错误状态码: %d", resp.StatusCode)
+		return errors.New(msgStatusCode.format(resp.StatusCode))
 	}
 
-	// 创建目标文件
-	out, err := os.Create(fn)
+	// 先写入同目录下的临时文件, 完成后重命名为目标文件.
+	// 目标文件可能正被 mmap 模式的数据库映射, 原地截断会使正在进行的查询崩溃.
+	out, err := os.CreateTemp(filepath.Dir(fn), "."+filepath.Base(fn)+".tmp-*")
 	if err != nil {
-		return fmt.Errorf("创建文件失败: %v", err)
+		return msgCreateFile.wrap(err)
 	}
-	defer out.Close()
+	tmp := out.Name()
+	defer os.Remove(tmp) // 重命名成功后临时文件已不存在
 
 	// 获取文件大小
 	fileSize := resp.ContentLength
@@ -76,8 +79,18 @@ func (dl *Download) SaveToFile(fn string, progress ProgressFunc) error {
 
 	// 复制数据到文件
 	_, err = io.Copy(out, io.TeeReader(resp.Body, counter))
+	if err == nil {
+		err = out.Chmod(0o644)
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
 	if err != nil {
-		return fmt.Errorf("写入文件失败: %v", err)
+		return msgWriteFile.wrap(err)
+	}
+
+	if err := os.Rename(tmp, fn); err != nil {
+		return msgWriteFile.wrap(err)
 	}
 
 	return nil
@@ -0,0 +1,42 @@
+package ipdb_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownload_SaveToFile(t *testing.T) {
+	body := buildTestDB(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(body)
+	}))
+	defer srv.Close()
+
+	name := filepath.Join(t.TempDir(), "city.ipdb")
+	require.NoError(t, os.WriteFile(name, []byte("old"), 0o644))
+	old, err := os.Stat(name)
+	require.NoError(t, err)
+
+	dl, err := ipdb.NewDownload(srv.URL)
+	require.NoError(t, err)
+	require.NoError(t, dl.SaveToFile(name, nil))
+
+	// 目标文件被整体替换, 而不是原地改写
+	fi, err := os.Stat(name)
+	require.NoError(t, err)
+	assert.False(t, os.SameFile(old, fi))
+	got, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, body, got)
+
+	entries, err := os.ReadDir(filepath.Dir(name))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
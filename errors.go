@@ -0,0 +1,85 @@
+package ipdb
+
+import "time"
+
+// 错误分类. 包内返回的错误都用 %w 包装, 可以用 errors.Is 判断属于哪一类:
+// 参数无效、未找到记录, 或数据库文件损坏.
+var (
+	// ErrInvalidInput 参数无效: IP 地址格式错误, 数据库不支持该语言、IP 版本, 文件名为空, 数据库文件与构造函数的类型不符,
+	// 记录类型不是结构体, 没有可回滚的数据库, 或写入的内容不合法
+	ErrInvalidInput = kindError("invalid input", "无效的查询参数", nil)
+	// ErrNotFound 数据库中没有该地址的记录
+	ErrNotFound = kindError("ip not found", "未找到IP信息", nil)
+	// ErrDatabase 数据库文件损坏、格式错误或无法读取
+	ErrDatabase = kindError("database error", "数据库错误", nil)
+)
+
+// 具体的错误, 各自属于上面的某个分类, 例如 errors.Is(ErrDataNotExists, ErrNotFound) 为 true
+var (
+	ErrFileSize          = kindError("invalid database file size", "IP数据库文件大小错误", ErrDatabase)
+	ErrMetaData          = kindError("invalid database metadata", "IP数据库元数据错误", ErrDatabase)
+	ErrReadFull          = kindError("failed to read database", "IP数据库读取错误", ErrDatabase)
+	ErrClosed            = kindError("database is closed", "数据库已关闭", ErrDatabase)
+	ErrIPFormat          = kindError("invalid ip address format", "IP地址格式错误", ErrInvalidInput)
+	ErrNoSupportLanguage = kindError("language not supported", "不支持该语言", ErrInvalidInput)
+	ErrNoSupportIPv4     = kindError("ipv4 not supported", "不支持IPv4", ErrInvalidInput)
+	ErrNoSupportIPv6     = kindError("ipv6 not supported", "不支持IPv6", ErrInvalidInput)
+	ErrDataNotExists     = kindError("data not exists", "数据不存在", ErrNotFound)
+	ErrFileName          = kindError("database file name must not be empty", "数据库文件名不能为空", ErrInvalidInput)
+
+	// ErrInvalidIP 与 ErrIPFormat 是同一个错误, 保留以兼容 BaseStation 原有的用法
+	ErrInvalidIP = ErrIPFormat
+)
+
+// sentinelError 属于某个分类的哨兵错误, 文本按 SetErrorLanguage 的设置输出
+type sentinelError struct {
+	msg  message
+	kind error
+}
+
+// kindError 创建哨兵错误, kind 为所属分类, 分类本身传 nil
+func kindError(en, zh string, kind error) error {
+	return &sentinelError{msg: message{en, zh}, kind: kind}
+}
+
+func (e *sentinelError) Error() string {
+	return e.msg.String()
+}
+
+func (e *sentinelError) Unwrap() error {
+	return e.kind
+}
+
+// LookupError 查询失败时返回的错误, 记录查询的地址、语言和数据库.
+// 用 errors.As 取得上下文, 用 errors.Is 判断 Err 的具体原因或分类.
+type LookupError struct {
+	IP       string    // 查询的地址
+	Language string    // 查询的语言, 可以是语言回退链
+	Database Product   // 数据库的产品类型
+	Build    time.Time // 数据库构建时间
+	Err      error     // 失败原因
+}
+
+func (e *LookupError) Error() string {
+	return msgLookup.format(e.IP, e.Language, e.Database) + ": " + e.Err.Error()
+}
+
+func (e *LookupError) Unwrap() error {
+	return e.Err
+}
+
+// lookupError 为尚未取得快照的查询 (参数无效、ctx 已取消、数据库已关闭) 附加当前快照的上下文
+func (db *database) lookupError(addr, language string, err error) error {
+	return db.snap.Load().lookupError(addr, language, err)
+}
+
+// lookupError 为查询错误附加上下文, 数据库信息取自查询实际使用的快照
+func (s *snapshot) lookupError(addr, language string, err error) error {
+	return &LookupError{
+		IP:       addr,
+		Language: language,
+		Database: s.product,
+		Build:    s.reader.Build(),
+		Err:      err,
+	}
+}
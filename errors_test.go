@@ -0,0 +1,108 @@
+package ipdb_test
+
+import (
+	"errors"
+	"net/netip"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorKinds(t *testing.T) {
+	for _, err := range []error{
+		ipdb.ErrIPFormat, ipdb.ErrNoSupportLanguage, ipdb.ErrNoSupportIPv4, ipdb.ErrNoSupportIPv6, ipdb.ErrFileName,
+		ipdb.ErrWriterFields, ipdb.ErrWriterLanguages, ipdb.ErrWriterValues, ipdb.ErrWriterRecord, ipdb.ErrWriterPrefix,
+		ipdb.ErrProductMismatch, ipdb.ErrRecordType, ipdb.ErrNoRollback,
+	} {
+		assert.ErrorIs(t, err, ipdb.ErrInvalidInput, err.Error())
+		assert.NotErrorIs(t, err, ipdb.ErrDatabase, err.Error())
+	}
+	for _, err := range []error{ipdb.ErrFileSize, ipdb.ErrMetaData, ipdb.ErrReadFull, ipdb.ErrDecompressedSize, ipdb.ErrFieldValue} {
+		assert.ErrorIs(t, err, ipdb.ErrDatabase, err.Error())
+	}
+	assert.ErrorIs(t, ipdb.ErrDataNotExists, ipdb.ErrNotFound)
+	assert.ErrorIs(t, ipdb.ErrFieldEmpty, ipdb.ErrNotFound)
+	assert.Equal(t, ipdb.ErrIPFormat, ipdb.ErrInvalidIP)
+}
+
+func TestLookupError(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	_, err = city.FindInfo("8.8.8.8", "EN")
+	assert.ErrorIs(t, err, ipdb.ErrDataNotExists)
+	assert.ErrorIs(t, err, ipdb.ErrNotFound)
+
+	var le *ipdb.LookupError
+	require.ErrorAs(t, err, &le)
+	assert.Equal(t, "8.8.8.8", le.IP)
+	assert.Equal(t, "EN", le.Language)
+	assert.Equal(t, ipdb.ProductCity, le.Database)
+	assert.Equal(t, int64(1700000000), le.Build.Unix())
+	assert.Contains(t, err.Error(), "8.8.8.8")
+
+	_, err = city.FindInfoAddr(netip.MustParseAddr("2001:db8::1"), "JP")
+	require.ErrorAs(t, err, &le)
+	assert.Equal(t, "2001:db8::1", le.IP)
+	assert.ErrorIs(t, err, ipdb.ErrInvalidInput)
+
+	// BaseStation 与其他类型使用相同的哨兵错误
+	bs, err := ipdb.NewBaseStationFromBytes(buildProductDB(t, productFields[ipdb.ProductBaseStation]...))
+	require.NoError(t, err)
+	_, err = bs.FindInfo("bad", "CN")
+	assert.ErrorIs(t, err, ipdb.ErrInvalidIP)
+	results := bs.BatchFind([]string{"8.8.8.8"}, "CN")
+	assert.ErrorIs(t, results[0].Error, ipdb.ErrNotFound)
+
+	// 自定义结构体的数据库按字段识别产品类型
+	asn, _, err := ipdb.OpenAutoBytes(buildProductDB(t, productFields[ipdb.ProductASN]...))
+	require.NoError(t, err)
+	_, err = asn.Find("8.8.8.8", "CN")
+	require.ErrorAs(t, err, &le)
+	assert.Equal(t, ipdb.ProductASN, le.Database)
+
+	_, err = ipdb.NewRisk("")
+	assert.ErrorIs(t, err, ipdb.ErrFileName)
+	assert.ErrorIs(t, err, ipdb.ErrInvalidInput)
+
+	// 构造函数保留底层错误
+	_, err = ipdb.NewCityFromBytes([]byte{0, 0})
+	assert.ErrorIs(t, err, ipdb.ErrFileSize)
+	assert.ErrorIs(t, err, ipdb.ErrDatabase)
+	_, err = ipdb.NewCityFromBytes(buildTestDB(t)[:100])
+	assert.True(t, errors.Is(err, ipdb.ErrDatabase))
+	_, err = ipdb.NewBaseStationFromBytes([]byte{0, 0})
+	assert.ErrorIs(t, err, ipdb.ErrFileSize)
+	assert.Contains(t, err.Error(), "BaseStation")
+
+	// 文件与构造函数的类型不符属于参数无效
+	_, err = ipdb.NewCityFromBytes(buildProductDB(t, productFields[ipdb.ProductASN]...))
+	assert.ErrorIs(t, err, ipdb.ErrProductMismatch)
+	assert.ErrorIs(t, err, ipdb.ErrInvalidInput)
+}
+
+func TestErrorLanguage(t *testing.T) {
+	defer ipdb.SetErrorLanguage(ipdb.ErrorLanguageEnglish)
+
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	assert.Equal(t, ipdb.ErrorLanguageEnglish, ipdb.CurrentErrorLanguage())
+	assert.Equal(t, "invalid ip address format", ipdb.ErrIPFormat.Error())
+	_, err = city.FindInfo("8.8.8.8", "CN")
+	assert.Equal(t, "lookup failed (ip=8.8.8.8, language=CN, database=city): data not exists", err.Error())
+
+	ipdb.SetErrorLanguage(ipdb.ErrorLanguageChinese)
+	assert.Equal(t, "IP地址格式错误", ipdb.ErrIPFormat.Error())
+	_, err = city.FindInfo("8.8.8.8", "CN")
+	assert.Equal(t, "查找IP信息失败 (ip=8.8.8.8, language=CN, database=city): 数据不存在", err.Error())
+	// 切换语言不影响错误的判断
+	assert.ErrorIs(t, err, ipdb.ErrDataNotExists)
+	assert.ErrorIs(t, err, ipdb.ErrNotFound)
+
+	_, err = ipdb.NewDistrictFromBytes(buildTestDB(t))
+	assert.ErrorIs(t, err, ipdb.ErrProductMismatch)
+	assert.Contains(t, err.Error(), "需要 district 数据库")
+}
@@ -0,0 +1,128 @@
+package ipdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func fuzzSeed(tb testing.TB) []byte {
+	w, err := NewWriter([]string{"country_name", "region_name", "city_name"}, "CN", "EN")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	for cidr, values := range map[string]map[string][]string{
+		"1.0.0.0/8":     {"CN": {"澳大利亚", "", ""}, "EN": {"Australia", "", ""}},
+		"1.2.3.0/24":    {"CN": {"中国", "北京", "北京"}, "EN": {"China", "Beijing", "Beijing"}},
+		"2001:db8::/32": {"CN": {"保留地址", "", ""}, "EN": {"Reserved", "", ""}},
+	} {
+		if err := w.InsertCIDR(cidr, values); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	body, err := w.Bytes()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return body
+}
+
+func TestInitBytesTruncated(t *testing.T) {
+	body := fuzzSeed(t)
+	for i := 0; i < len(body); i++ {
+		if _, err := newReaderFromBytes(body[:i], &CityInfo{}); err == nil {
+			t.Fatalf("truncated database of %d bytes loaded without error", i)
+		}
+	}
+}
+
+func TestInitBytesMalformedNodes(t *testing.T) {
+	body := fuzzSeed(t)
+	metaLength := int(binary.BigEndian.Uint32(body[0:4]))
+	nodes := body[4+metaLength:]
+
+	// 记录偏移指向数据区之外
+	bad := append([]byte(nil), body...)
+	binary.BigEndian.PutUint32(bad[4+metaLength:], 0xfffffff0)
+	_, err := newReaderFromBytes(bad, &CityInfo{})
+	var fe *FormatError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrDatabase) {
+		t.Fatalf("expected FormatError, got %v", err)
+	}
+
+	// 子节点指回根节点形成环
+	bad = append([]byte(nil), body...)
+	copy(bad[4+metaLength+4:], []byte{0, 0, 0, 0})
+	if binary.BigEndian.Uint32(nodes[4:8]) != 0 {
+		if _, err := newReaderFromBytes(bad, &CityInfo{}); !errors.As(err, &fe) {
+			t.Fatalf("expected FormatError for cycle, got %v", err)
+		}
+	}
+
+	// 根节点的两个子节点指向同一个节点
+	bad = append([]byte(nil), body...)
+	copy(bad[4+metaLength+4:], nodes[0:4])
+	_, err = newReaderFromBytes(bad, &CityInfo{})
+	if !errors.As(err, &fe) || !errors.Is(err, ErrDatabase) {
+		t.Fatalf("expected FormatError for shared node, got %v", err)
+	}
+}
+
+func TestInitBytesSharedNodes(t *testing.T) {
+	// 每个节点的两个子节点都指向下一个节点, 按树遍历需要 2^n 步
+	const n = 128
+	meta := []byte(`{"build":1700000000,"ip_version":1,"languages":{"CN":0},"node_count":128,"total_size":1040,"fields":["country_name"]}`)
+	body := binary.BigEndian.AppendUint32(nil, uint32(len(meta)))
+	body = append(body, meta...)
+	for i := 0; i < n; i++ {
+		next := uint32(i + 1)
+		if i == n-1 {
+			next = n + 8 // 叶子
+		}
+		body = binary.BigEndian.AppendUint32(body, next)
+		body = binary.BigEndian.AppendUint32(body, next)
+	}
+	body = append(body, make([]byte, 8)...)
+	body = append(body, 0, 6, 'C', 'h', 'i', 'n', 'a', '\t')
+
+	_, err := newReaderFromBytes(body, &CityInfo{})
+	var fe *FormatError
+	if !errors.As(err, &fe) || !errors.Is(err, ErrDatabase) {
+		t.Fatalf("expected FormatError for shared nodes, got %v", err)
+	}
+}
+
+func FuzzInitBytes(f *testing.F) {
+	f.Add(fuzzSeed(f))
+	f.Add([]byte{0, 0, 0, 2, '{', '}'})
+	f.Fuzz(func(t *testing.T, body []byte) {
+		db, err := newReaderFromBytes(body, &CityInfo{})
+		if err != nil {
+			return
+		}
+		db.walk(func(_ *net.IPNet, node int) bool {
+			_, _ = db.resolve(node)
+			return true
+		})
+	})
+}
+
+func FuzzFind(f *testing.F) {
+	seed := fuzzSeed(f)
+	f.Add(seed, "1.2.3.4", "CN")
+	f.Add(seed, "2001:db8::1", "EN")
+	f.Add(seed, "::ffff:1.1.1.1", "CN")
+	f.Fuzz(func(t *testing.T, body []byte, addr, language string) {
+		db, err := newReaderFromBytes(body, &CityInfo{})
+		if err != nil {
+			return
+		}
+		_, _ = db.find1(addr, language)
+		_, _, _ = db.locate(addr)
+		if ip, err := netip.ParseAddr(addr); err == nil {
+			_, _, _ = db.findAddr(ip, language)
+		}
+	})
+}
@@ -1,5 +1,14 @@
 module github.com/soulteary/ipdb-go
 
-go 1.14
+go 1.22
 
-require github.com/stretchr/testify v1.10.0
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/stretchr/testify v1.10.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
@@ -1,11 +1,11 @@
 package ipdb
 
 import (
-	"fmt"
-	"os"
-	"reflect"
-	"sync"
-	"time"
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
 )
 
 type IDCInfo struct {
@@ -18,128 +18,137 @@ type IDCInfo struct {
 }
 
 type IDC struct {
-	reader *reader
-	cache  *sync.Map
-	mu     sync.RWMutex
+	database
+}
+
+// newIDCDB 使用 reader 创建数据库实例
+func newIDCDB(r *reader) (*IDC, error) {
+	db := &IDC{}
+	if err := db.init(r, &IDCInfo{}, ProductIDC); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 func NewIDC(name string) (*IDC, error) {
 	r, e := newReader(name, &IDCInfo{})
 	if e != nil {
-		return nil, fmt.Errorf("初始化IDC数据库失败: %v", e)
+		return nil, msgInit.wrap(e, "IDC")
 	}
 
-	return &IDC{
-		reader: r,
-		cache:  &sync.Map{},
-	}, nil
+	return newIDCDB(r)
 }
 
-func (db *IDC) Reload(name string) error {
-	db.mu.Lock()
-	defer db.mu.Unlock()
-
-	if _, err := os.Stat(name); err != nil {
-		return fmt.Errorf("数据库文件不存在: %v", err)
-	}
-
-	reader, err := newReader(name, &IDCInfo{})
-	if err != nil {
-		return fmt.Errorf("加载数据库失败: %v", err)
+// NewIDCMmap 通过 mmap 加载数据库, 使用完毕后调用 Close 释放
+func NewIDCMmap(name string) (*IDC, error) {
+	r, e := newReaderMmap(name, &IDCInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "IDC")
 	}
 
-	db.reader = reader
-	db.ClearCache()
-
-	return nil
+	return newIDCDB(r)
 }
 
-func (db *IDC) Find(addr, language string) ([]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewIDCFromBytes 从字节数据初始化
+func NewIDCFromBytes(bs []byte) (*IDC, error) {
+	r, e := newReaderFromBytes(bs, &IDCInfo{})
+	if e != nil {
+		return nil, msgInitBytes.wrap(e, "IDC")
 	}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.reader.find1(addr, language)
+	return newIDCDB(r)
 }
 
-func (db *IDC) FindMap(addr, language string) (map[string]string, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewIDCFromReader 从 io.Reader 初始化, maxSize 大于 0 时限制读取的最大字节数
+func NewIDCFromReader(rd io.Reader, maxSize int64) (*IDC, error) {
+	r, e := newReaderFromReader(rd, maxSize, &IDCInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "IDC")
 	}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+	return newIDCDB(r)
+}
 
-	data, err := db.reader.find1(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
-	}
-	info := make(map[string]string, len(db.reader.meta.Fields))
-	for k, v := range data {
-		info[db.reader.meta.Fields[k]] = v
+// NewIDCFromReaderAt 从 io.ReaderAt 读取 size 字节初始化
+func NewIDCFromReaderAt(rd io.ReaderAt, size int64) (*IDC, error) {
+	r, e := newReaderFromReaderAt(rd, size, &IDCInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "IDC")
 	}
 
-	return info, nil
+	return newIDCDB(r)
 }
 
-func (db *IDC) FindInfo(addr, language string) (*IDCInfo, error) {
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewIDCFromFS 从 fs.FS 中的文件初始化, 可用于 embed.FS
+func NewIDCFromFS(fsys fs.FS, name string) (*IDC, error) {
+	r, e := newReaderFromFS(fsys, name, &IDCInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "IDC")
 	}
 
-	cacheKey := addr + language
-	if val, ok := db.cache.Load(cacheKey); ok {
-		if info, ok := val.(*IDCInfo); ok {
-			return info, nil
-		}
-	}
+	return newIDCDB(r)
+}
 
-	db.mu.RLock()
-	defer db.mu.RUnlock()
+func (db *IDC) FindInfo(addr, language string) (*IDCInfo, error) {
+	return db.FindInfoContext(context.Background(), addr, language)
+}
 
-	data, err := db.reader.FindMap(addr, language)
-	if err != nil {
-		return nil, fmt.Errorf("查找IP信息失败: %v", err)
-	}
+// FindInfoContext 与 FindInfo 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *IDC) FindInfoContext(ctx context.Context, addr, language string) (*IDCInfo, error) {
+	info, _, err := findInfo(ctx, &db.database, addr, language, decodeIDCInfo)
+	return info, err
+}
 
+// decodeIDCInfo 将字段映射解析为 IDCInfo
+func decodeIDCInfo(r *reader, data []string) *IDCInfo {
 	info := &IDCInfo{}
-	val := reflect.ValueOf(info).Elem()
-
-	for k, v := range data {
-		field := val.FieldByName(db.reader.refType[k])
-		if !field.IsValid() || !field.CanSet() {
-			continue
-		}
-		field.SetString(v)
-	}
+	r.fill(info, data)
+	return info
+}
 
-	db.cache.Store(cacheKey, info)
+// FindInfoWithNetwork 查找IP信息, 同时返回命中的网络
+func (db *IDC) FindInfoWithNetwork(addr, language string) (*IDCInfo, *net.IPNet, error) {
+	return findInfo(context.Background(), &db.database, addr, language, decodeIDCInfo)
+}
+
+// FindInfoAddr 使用 netip.Addr 查找IP信息
+func (db *IDC) FindInfoAddr(addr netip.Addr, language string) (*IDCInfo, error) {
+	info, _, err := db.FindInfoWithPrefix(addr, language)
+	return info, err
+}
 
-	return info, nil
+// FindInfoWithPrefix 使用 netip.Addr 查找IP信息, 同时返回命中的网络前缀
+func (db *IDC) FindInfoWithPrefix(addr netip.Addr, language string) (*IDCInfo, netip.Prefix, error) {
+	return findInfoAddr(&db.database, addr, language, decodeIDCInfo)
 }
 
-func (db *IDC) ClearCache() {
-	db.cache = &sync.Map{}
+// FindRange 返回 prefix 范围内的每个网络及其记录, 按地址顺序排列.
+// prefix 落在一个更大的网络中时, 返回 prefix 本身及该网络的记录.
+func (db *IDC) FindRange(prefix netip.Prefix, language string) ([]RangeItem[IDCInfo], error) {
+	return db.FindRangeContext(context.Background(), prefix, language)
 }
 
-func (db *IDC) IsIPv4() bool {
-	return db.reader.IsIPv4Support()
+// FindRangeContext 与 FindRange 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (db *IDC) FindRangeContext(ctx context.Context, prefix netip.Prefix, language string) ([]RangeItem[IDCInfo], error) {
+	return findRange(ctx, &db.database, prefix, language, decodeIDCInfo)
 }
 
-func (db *IDC) IsIPv6() bool {
-	return db.reader.IsIPv6Support()
+// Walk 遍历数据库中的每个网络及其记录, fn 返回 false 时停止遍历
+func (db *IDC) Walk(language string, fn func(network *net.IPNet, info *IDCInfo) bool) error {
+	return db.WalkContext(context.Background(), language, fn)
 }
 
-func (db *IDC) Languages() []string {
-	return db.reader.Languages()
+// WalkContext 与 Walk 相同, 遍历过程中 ctx 取消时停止并返回 ctx 的错误
+func (db *IDC) WalkContext(ctx context.Context, language string, fn func(network *net.IPNet, info *IDCInfo) bool) error {
+	return walkInfo(ctx, &db.database, language, decodeIDCInfo, fn)
 }
 
-func (db *IDC) Fields() []string {
-	return db.reader.meta.Fields
+// BatchFind 并发查询多个地址, 按输入顺序返回每个地址的结果和错误, 所有地址使用同一个数据库快照
+func (db *IDC) BatchFind(addrs []string, language string) []BatchItem[IDCInfo] {
+	return db.BatchFindContext(context.Background(), addrs, language)
 }
 
-func (db *IDC) BuildTime() time.Time {
-	return db.reader.Build()
+// BatchFindContext 与 BatchFind 相同, ctx 取消后尚未查询的地址返回 ctx 的错误
+func (db *IDC) BatchFindContext(ctx context.Context, addrs []string, language string) []BatchItem[IDCInfo] {
+	return batchFind(ctx, &db.database, addrs, language, decodeIDCInfo)
 }
@@ -0,0 +1,222 @@
+package ipdb
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"net"
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// ErrIndexField 字段不存在或没有建立索引
+var ErrIndexField = kindError("field is not indexed", "字段未建立索引", ErrInvalidInput)
+
+// Index 由一次完整遍历建立的反向索引, 按字段值查找网络.
+// 索引建立后与数据库相互独立, Reload 之后需要重新建立.
+type Index struct {
+	language string
+	build    time.Time
+	networks []netip.Prefix                // 遍历顺序的网络, 下标即网络编号
+	postings map[string]map[string][]int32 // 字段 -> 字段值 -> 网络编号, 升序
+}
+
+// ValueCount 某个字段值对应的网络和地址数量
+type ValueCount struct {
+	Value    string
+	Networks int      // 网络数量
+	IPv4     uint64   // IPv4 地址数量
+	IPv6     *big.Int // IPv6 地址数量, 可能超出 uint64 的范围
+}
+
+// BuildIndex 遍历整个数据库, 为指定语言的 fields 建立反向索引, fields 为空时索引所有字段
+func (db *database) BuildIndex(language string, fields ...string) (*Index, error) {
+	return db.BuildIndexContext(context.Background(), language, fields...)
+}
+
+// BuildIndexContext 与 BuildIndex 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (db *database) BuildIndexContext(ctx context.Context, language string, fields ...string) (*Index, error) {
+	s, err := db.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer s.reader.release()
+
+	r := s.reader
+	if len(fields) == 0 {
+		fields = r.meta.Fields
+	}
+
+	pos := make([]int, len(fields))
+	ix := &Index{
+		language: language,
+		build:    r.Build(),
+		postings: make(map[string]map[string][]int32, len(fields)),
+	}
+	for i, f := range fields {
+		pos[i] = -1
+		for j, name := range r.meta.Fields {
+			if name == f {
+				pos[i] = j
+			}
+		}
+		if pos[i] < 0 {
+			return nil, fmt.Errorf("%w: %s", ErrIndexField, f)
+		}
+		ix.postings[f] = make(map[string][]int32)
+	}
+
+	err = r.walkRecords(ctx, language, func(network *net.IPNet, data []string) bool {
+		id := int32(len(ix.networks))
+		ix.networks = append(ix.networks, toPrefix(network))
+		for i, f := range fields {
+			values := ix.postings[f]
+			values[data[pos[i]]] = append(values[data[pos[i]]], id)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ix, nil
+}
+
+// Language 建立索引时使用的语言
+func (ix *Index) Language() string {
+	return ix.language
+}
+
+// BuildTime 建立索引时数据库的构建时间
+func (ix *Index) BuildTime() time.Time {
+	return ix.build
+}
+
+// Fields 建立了索引的字段
+func (ix *Index) Fields() []string {
+	fields := make([]string, 0, len(ix.postings))
+	for f := range ix.postings {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Len 索引中的网络数量
+func (ix *Index) Len() int {
+	return len(ix.networks)
+}
+
+// Values 返回字段的所有取值, 按字符串排序
+func (ix *Index) Values(field string) ([]string, error) {
+	values, ok := ix.postings[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexField, field)
+	}
+	list := make([]string, 0, len(values))
+	for v := range values {
+		list = append(list, v)
+	}
+	sort.Strings(list)
+	return list, nil
+}
+
+// Query 返回 filters 中所有字段都等于对应值的网络, 按地址顺序排列; filters 为空时返回所有网络
+func (ix *Index) Query(filters map[string]string) ([]netip.Prefix, error) {
+	ids, err := ix.match(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	networks := make([]netip.Prefix, len(ids))
+	for i, id := range ids {
+		networks[i] = ix.networks[id]
+	}
+	return networks, nil
+}
+
+// Count 在满足 filters 的网络中, 按 field 的取值统计网络和地址数量, 结果按取值排序
+func (ix *Index) Count(field string, filters map[string]string) ([]ValueCount, error) {
+	values, ok := ix.postings[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrIndexField, field)
+	}
+	ids, err := ix.match(filters)
+	if err != nil {
+		return nil, err
+	}
+
+	var selected map[int32]bool
+	if len(filters) > 0 {
+		selected = make(map[int32]bool, len(ids))
+		for _, id := range ids {
+			selected[id] = true
+		}
+	}
+
+	var counts []ValueCount
+	for value, list := range values {
+		c := ValueCount{Value: value, IPv6: new(big.Int)}
+		for _, id := range list {
+			if selected != nil && !selected[id] {
+				continue
+			}
+			c.Networks++
+			p := ix.networks[id]
+			if p.Addr().Is4() {
+				c.IPv4 += 1 << uint(32-p.Bits())
+			} else {
+				c.IPv6.Add(c.IPv6, new(big.Int).Lsh(big.NewInt(1), uint(128-p.Bits())))
+			}
+		}
+		if c.Networks > 0 {
+			counts = append(counts, c)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Value < counts[j].Value })
+
+	return counts, nil
+}
+
+// match 返回满足所有过滤条件的网络编号, 从最短的列表开始求交集
+func (ix *Index) match(filters map[string]string) ([]int32, error) {
+	if len(filters) == 0 {
+		ids := make([]int32, len(ix.networks))
+		for i := range ids {
+			ids[i] = int32(i)
+		}
+		return ids, nil
+	}
+
+	lists := make([][]int32, 0, len(filters))
+	for field, value := range filters {
+		values, ok := ix.postings[field]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrIndexField, field)
+		}
+		lists = append(lists, values[value])
+	}
+	sort.Slice(lists, func(i, j int) bool { return len(lists[i]) < len(lists[j]) })
+
+	ids := append([]int32(nil), lists[0]...)
+	for _, list := range lists[1:] {
+		ids = intersect(ids, list)
+	}
+	return ids, nil
+}
+
+// intersect 求两个升序列表的交集, 结果写回 a
+func intersect(a, b []int32) []int32 {
+	n, j := 0, 0
+	for _, v := range a {
+		for j < len(b) && b[j] < v {
+			j++
+		}
+		if j < len(b) && b[j] == v {
+			a[n] = v
+			n++
+		}
+	}
+	return a[:n]
+}
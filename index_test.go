@@ -0,0 +1,89 @@
+package ipdb_test
+
+import (
+	"context"
+	"math/big"
+	"net/netip"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndex(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	ix, err := city.BuildIndex("EN")
+	require.NoError(t, err)
+	assert.Equal(t, "EN", ix.Language())
+	assert.Equal(t, int64(1700000000), ix.BuildTime().Unix())
+	assert.Equal(t, []string{"city_name", "country_name", "region_name"}, ix.Fields())
+
+	networks, err := ix.Query(map[string]string{"country_name": "China", "city_name": "Beijing"})
+	require.NoError(t, err)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("1.2.3.0/24")}, networks)
+
+	networks, err = ix.Query(map[string]string{"country_name": "Australia", "city_name": "Beijing"})
+	require.NoError(t, err)
+	assert.Empty(t, networks)
+
+	all, err := ix.Query(nil)
+	require.NoError(t, err)
+	assert.Len(t, all, ix.Len())
+
+	values, err := ix.Values("country_name")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Australia", "China", "Reserved"}, values)
+
+	counts, err := ix.Count("country_name", nil)
+	require.NoError(t, err)
+	require.Len(t, counts, 3)
+	assert.Equal(t, "Australia", counts[0].Value)
+	assert.Equal(t, uint64(1<<24-256), counts[0].IPv4)
+	assert.Equal(t, uint64(256), counts[1].IPv4)
+	assert.Equal(t, 1, counts[1].Networks)
+	assert.Equal(t, new(big.Int).Lsh(big.NewInt(1), 96), counts[2].IPv6)
+
+	counts, err = ix.Count("country_name", map[string]string{"city_name": ""})
+	require.NoError(t, err)
+	require.Len(t, counts, 2)
+	assert.Equal(t, "Reserved", counts[1].Value)
+
+	_, err = ix.Query(map[string]string{"isp_domain": "x"})
+	assert.ErrorIs(t, err, ipdb.ErrIndexField)
+	assert.ErrorIs(t, err, ipdb.ErrInvalidInput)
+	_, err = city.BuildIndex("EN", "isp_domain")
+	assert.ErrorIs(t, err, ipdb.ErrIndexField)
+	_, err = city.BuildIndex("JP")
+	assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = city.BuildIndexContext(ctx, "EN")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIndexMatchesFind(t *testing.T) {
+	ix, err := db.BuildIndex("CN", "country_name", "region_name")
+	require.NoError(t, err)
+
+	networks, err := ix.Query(map[string]string{"country_name": "中国", "region_name": "广东"})
+	require.NoError(t, err)
+	require.NotEmpty(t, networks)
+	for i := 0; i < len(networks); i += 97 {
+		info, err := db.FindInfoAddr(networks[i].Addr(), "CN")
+		require.NoError(t, err)
+		assert.Equal(t, "中国", info.CountryName)
+		assert.Equal(t, "广东", info.RegionName)
+	}
+
+	counts, err := ix.Count("region_name", map[string]string{"country_name": "中国"})
+	require.NoError(t, err)
+	for _, c := range counts {
+		if c.Value == "广东" {
+			assert.Equal(t, len(networks), c.Networks)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package ipdb
+
+import "strings"
+
+// languageSeparator 语言回退链中各语言之间的分隔符
+const languageSeparator = ","
+
+// Languages 组成语言回退链, 可以代替单个语言传给各查询方法, 例如
+// db.FindInfo(addr, ipdb.Languages("EN", "CN")).
+// 每个字段依次尝试链中的语言, 使用第一个非空值; 数据库不支持的语言被跳过.
+func Languages(languages ...string) string {
+	return strings.Join(languages, languageSeparator)
+}
+
+// languageChain 返回回退链中数据库支持的语言
+func (db *reader) languageChain(language string) []string {
+	var chain []string
+	for _, lang := range strings.Split(language, languageSeparator) {
+		if _, ok := db.meta.Languages[lang]; ok {
+			chain = append(chain, lang)
+		}
+	}
+	return chain
+}
+
+// hasLanguage 数据库是否支持该语言, 对回退链只要求其中一个语言受支持
+func (db *reader) hasLanguage(language string) bool {
+	if _, ok := db.meta.Languages[language]; ok {
+		return true
+	}
+	return strings.Contains(language, languageSeparator) && len(db.languageChain(language)) > 0
+}
+
+// splitChain 按回退链解析记录, 每个字段取第一个非空值
+func (db *reader) splitChain(body []byte, language string) ([]string, error) {
+	var values []string
+	for _, lang := range db.languageChain(language) {
+		data, err := db.split(body, lang)
+		if err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = data
+			continue
+		}
+
+		missing := false
+		for k, v := range values {
+			if v == "" {
+				values[k] = data[k]
+				missing = missing || data[k] == ""
+			}
+		}
+		if !missing {
+			break
+		}
+	}
+	if values == nil {
+		return nil, ErrNoSupportLanguage
+	}
+	return values, nil
+}
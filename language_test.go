@@ -0,0 +1,51 @@
+package ipdb_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLanguageFallback(t *testing.T) {
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name"}, "CN", "EN")
+	require.NoError(t, err)
+	require.NoError(t, w.InsertCIDR("1.2.3.0/24", map[string][]string{
+		"CN": {"中国", "北京", ""},
+		"EN": {"China", "", ""},
+	}))
+	body, err := w.Bytes()
+	require.NoError(t, err)
+
+	city, err := ipdb.NewCityFromBytes(body)
+	require.NoError(t, err)
+
+	data, err := city.Find("1.2.3.4", ipdb.Languages("EN", "CN"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"China", "北京", ""}, data)
+
+	info, err := city.FindInfo("1.2.3.4", ipdb.Languages("EN", "CN"))
+	require.NoError(t, err)
+	assert.Equal(t, "China", info.CountryName)
+	assert.Equal(t, "北京", info.RegionName)
+
+	// 单个语言不回退
+	info, err = city.FindInfo("1.2.3.4", "EN")
+	require.NoError(t, err)
+	assert.Empty(t, info.RegionName)
+
+	// 不支持的语言被跳过
+	m, err := city.FindMap("1.2.3.4", ipdb.Languages("JP", "EN"))
+	require.NoError(t, err)
+	assert.Equal(t, "China", m["country_name"])
+
+	_, err = city.Find("1.2.3.4", ipdb.Languages("JP", "KO"))
+	assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+
+	require.NoError(t, city.Walk(ipdb.Languages("EN", "CN"), func(_ *net.IPNet, info *ipdb.CityInfo) bool {
+		assert.Equal(t, "北京", info.RegionName)
+		return true
+	}))
+}
@@ -0,0 +1,103 @@
+package ipdb
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// decodeFunc 将记录的字段值解析为 T
+type decodeFunc[T any] func(r *reader, data []string) *T
+
+// findInfo 在同一快照中查找 addr, 返回解析后的记录和命中的网络. ctx 已取消或超时时直接返回 ctx 的错误.
+func findInfo[T any](ctx context.Context, db *database, addr, language string, decode decodeFunc[T]) (*T, *net.IPNet, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, db.lookupError(addr, language, err)
+	}
+	// 验证IP地址
+	if err := validateIP(addr); err != nil {
+		return nil, nil, db.lookupError(addr, language, err)
+	}
+
+	s, err := db.acquire()
+	if err != nil {
+		return nil, nil, db.lookupError(addr, language, err)
+	}
+	defer s.reader.release()
+
+	node, network, err := s.reader.locate(addr)
+	if err != nil {
+		return nil, nil, s.lookupError(addr, language, err)
+	}
+
+	info, err := cachedInfo(s, node, language, decode)
+	if err != nil {
+		return nil, nil, s.lookupError(addr, language, err)
+	}
+
+	return info, network, nil
+}
+
+// findInfoAddr 与 findInfo 相同, 使用 netip.Addr 查找并返回命中的网络前缀
+func findInfoAddr[T any](db *database, addr netip.Addr, language string, decode decodeFunc[T]) (*T, netip.Prefix, error) {
+	s, err := db.acquire()
+	if err != nil {
+		return nil, netip.Prefix{}, db.lookupError(addr.String(), language, err)
+	}
+	defer s.reader.release()
+
+	node, network, err := s.reader.locateAddr(addr)
+	if err != nil {
+		return nil, netip.Prefix{}, s.lookupError(addr.String(), language, err)
+	}
+
+	info, err := cachedInfo(s, node, language, decode)
+	if err != nil {
+		return nil, netip.Prefix{}, s.lookupError(addr.String(), language, err)
+	}
+
+	return info, network, nil
+}
+
+// cachedInfo 按记录偏移读取快照中的缓存, 未命中时解析记录并写入缓存.
+// 已缓存其他类型的结果时追加而不是替换, 同一记录交替以不同类型查询时不会互相覆盖.
+func cachedInfo[T any](s *snapshot, node int, language string, decode decodeFunc[T]) (*T, error) {
+	key := CacheKey{Offset: node, Language: language}
+	values, _ := s.get(key)
+	for _, val := range values {
+		if info, ok := val.(*T); ok {
+			return info, nil
+		}
+	}
+
+	data, err := s.reader.record(node, language)
+	if err != nil {
+		return nil, err
+	}
+
+	info := decode(s.reader, data)
+	// 切片可能正被其他查询读取, 追加时复制
+	s.set(key, append(values[:len(values):len(values)], info))
+
+	return info, nil
+}
+
+// decodeMap 将记录解析为字段名到值的映射, 供 FindMap 缓存
+func decodeMap(r *reader, data []string) *map[string]string {
+	m := r.toMap(data)
+	return &m
+}
+
+// walkInfo 在同一快照中遍历每个网络, 将记录解析为 T 后交给 fn, fn 返回 false 时停止遍历
+func walkInfo[T any](ctx context.Context, db *database, language string, decode decodeFunc[T], fn func(network *net.IPNet, info *T) bool) error {
+	s, err := db.acquire()
+	if err != nil {
+		return err
+	}
+	defer s.reader.release()
+
+	r := s.reader
+	return r.walkRecords(ctx, language, func(network *net.IPNet, data []string) bool {
+		return fn(network, decode(r, data))
+	})
+}
@@ -0,0 +1,86 @@
+package ipdb
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrorLanguage 错误信息使用的语言
+type ErrorLanguage int32
+
+const (
+	ErrorLanguageEnglish ErrorLanguage = iota // 默认
+	ErrorLanguageChinese
+)
+
+var errorLanguage atomic.Int32
+
+// SetErrorLanguage 设置整个进程中错误信息使用的语言, 默认为英文.
+// 哨兵错误的文本随设置即时变化, 但 errors.Is 的判断结果不受影响;
+// 已经生成的包装错误保留生成时的文本.
+func SetErrorLanguage(l ErrorLanguage) {
+	errorLanguage.Store(int32(l))
+}
+
+// CurrentErrorLanguage 返回当前错误信息使用的语言
+func CurrentErrorLanguage() ErrorLanguage {
+	return ErrorLanguage(errorLanguage.Load())
+}
+
+// message 同时提供英文和中文的文本, 按 SetErrorLanguage 的设置输出
+type message struct {
+	en string
+	zh string
+}
+
+func (m message) String() string {
+	if CurrentErrorLanguage() == ErrorLanguageChinese {
+		return m.zh
+	}
+	return m.en
+}
+
+// format 以当前语言的文本为格式化字符串
+func (m message) format(args ...interface{}) string {
+	return fmt.Sprintf(m.String(), args...)
+}
+
+// wrap 在 err 前加上说明, 保留 err 供 errors.Is/As 判断
+func (m message) wrap(err error, args ...interface{}) error {
+	return fmt.Errorf("%s: %w", m.format(args...), err)
+}
+
+// 包装错误和错误类型使用的文本
+var (
+	msgInit       = message{"failed to initialize %s database", "初始化%s数据库失败"}
+	msgInitBytes  = message{"failed to initialize %s database from bytes", "从字节数据初始化%s数据库失败"}
+	msgFileAbsent = message{"database file does not exist", "数据库文件不存在"}
+	msgReload     = message{"failed to load database", "加载数据库失败"}
+	msgLookup     = message{"lookup failed (ip=%s, language=%s, database=%s)", "查找IP信息失败 (ip=%s, language=%s, database=%s)"}
+	msgMismatch   = message{"want %s database, got %s (fields: %s, missing: %s)", "需要 %s 数据库, 实际为 %s (字段: %s, 缺少: %s)"}
+	msgFieldEmpty = message{"field %s", "字段 %s"}
+	msgFieldValue = message{"field %s value %q", "字段 %s 的值 %q"}
+	msgFormat     = message{"node %d, offset %d: %s", "节点 %d, 偏移 %d: %s"}
+	msgParseCIDR  = message{"failed to parse CIDR", "解析CIDR失败"}
+
+	msgRecordOffset = message{"record offset out of range", "记录偏移越界"}
+	msgRecordLength = message{"record length out of range", "记录长度越界"}
+	msgNodeCycle    = message{"node cycle detected", "节点存在环"}
+	msgNodeParents  = message{"node %d has multiple parents", "节点 %d 存在多个父节点"}
+
+	msgVerifyLanguage  = message{"offset %[2]d of language %[1]s exceeds the record", "语言 %s 的偏移 %d 超出记录范围"}
+	msgVerifyCycle     = message{"child node %d forms a cycle", "子节点 %d 形成环"}
+	msgVerifyReachable = message{"metadata declares %d nodes, %d reachable", "元数据声明 %d 个节点, 可达节点 %d 个"}
+	msgVerifyLength    = message{"record length %d out of range", "记录长度 %d 越界"}
+	msgVerifyValues    = message{"record has %d values, want %d", "记录包含 %d 个值, 应为 %d 个"}
+	msgVerifyTrailing  = message{"%d bytes after the declared total_size %d", "声明的 total_size %[2]d 之后还有 %[1]d 字节"}
+	msgVerifySize      = message{"metadata declares total_size %d, %d used", "元数据声明 total_size %d, 实际使用 %d"}
+	msgVerifyIPv4      = message{"metadata declares IPv4 support but the IPv4 subtree is unreachable", "元数据声明支持 IPv4, 但 IPv4 子树不可达"}
+
+	msgParseURL    = message{"failed to parse URL", "解析URL失败"}
+	msgNewRequest  = message{"failed to create request", "创建请求失败"}
+	msgSendRequest = message{"failed to send request", "发送请求失败"}
+	msgStatusCode  = message{"server returned status code %d", "服务器返回错误状态码: %d"}
+	msgCreateFile  = message{"failed to create file", "创建文件失败"}
+	msgWriteFile   = message{"failed to write file", "写入文件失败"}
+)
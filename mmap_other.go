@@ -0,0 +1,21 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package ipdb
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile 在不支持 mmap 的平台上退化为读入整个文件
+func mmapFile(f *os.File, size int) ([]byte, error) {
+	body := make([]byte, size)
+	if _, err := io.ReadFull(f, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func munmap(b []byte) error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package ipdb
+
+import "strings"
+
+// Product 数据库对应的 IPIP.net 产品类型
+type Product string
+
+const (
+	ProductCity        Product = "city"
+	ProductDistrict    Product = "district"
+	ProductIDC         Product = "idc"
+	ProductBaseStation Product = "base_station"
+	ProductRisk        Product = "risk"
+	ProductASN         Product = "asn"
+	ProductCustom      Product = "custom" // 无法识别的字段组合
+)
+
+var ErrProductMismatch = kindError("database product does not match its fields", "数据库类型与字段不匹配", ErrInvalidInput)
+
+// ProductError 数据库缺少期望的产品类型必需的字段, 可以用 errors.Is(err, ErrProductMismatch) 判断
+type ProductError struct {
+	Want    Product  // 期望的产品类型
+	Got     Product  // 根据字段识别出的产品类型
+	Fields  []string // 数据库中的字段
+	Missing []string // 缺少的必需字段
+}
+
+func (e *ProductError) Error() string {
+	return ErrProductMismatch.Error() + ": " + msgMismatch.format(e.Want, e.Got, strings.Join(e.Fields, ","), strings.Join(e.Missing, ","))
+}
+
+func (e *ProductError) Unwrap() error {
+	return ErrProductMismatch
+}
+
+// ASN ASN 数据库, 记录解析为 ASNInfo
+type ASN = Typed[ASNInfo]
+
+// Custom 无法识别产品类型的数据库, 通过 Find/FindMap 查询, 或使用 Open[T] 解析为自定义结构体
+type Custom = Typed[struct{}]
+
+// productSpec 产品的记录结构、必需字段及用于区分的特征字段
+type productSpec struct {
+	product  Product
+	obj      func() interface{}
+	required []string // 记录结构必需的字段
+	markers  []string // 至少包含其中一个字段, 为空表示不要求
+}
+
+// products 按识别顺序排列, 字段较少、特征明确的产品在前, City 字段最全, 作为兜底
+var products = []productSpec{
+	{ProductRisk, func() interface{} { return &RiskInfo{} }, []string{"score", "behavior"}, []string{"score", "behavior"}},
+	{ProductDistrict, func() interface{} { return &DistrictInfo{} }, []string{"country_name", "region_name", "city_name", "district_name"}, []string{"district_name", "covering_radius"}},
+	{ProductBaseStation, func() interface{} { return &BaseStationInfo{} }, []string{"country_name", "region_name", "city_name", "base_station"}, []string{"base_station"}},
+	{ProductIDC, func() interface{} { return &IDCInfo{} }, []string{"country_name", "region_name", "city_name", "idc"}, []string{"idc"}},
+	{ProductASN, func() interface{} { return &ASNInfo{} }, []string{"asn"}, []string{"asn"}},
+	{ProductCity, func() interface{} { return &CityInfo{} }, []string{"country_name", "region_name", "city_name"}, nil},
+}
+
+// missing 返回 fields 中缺少的必需字段
+func (p productSpec) missing(fields []string) []string {
+	var list []string
+	for _, r := range p.required {
+		found := false
+		for _, f := range fields {
+			if f == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+// matches 所有字段都能对应到记录结构, 包含全部必需字段和至少一个特征字段.
+// 用于 OpenAuto 的精确识别, 构造函数只检查必需字段.
+func (p productSpec) matches(fields []string) bool {
+	if len(p.missing(fields)) > 0 {
+		return false
+	}
+	index := fieldIndex(p.obj(), fields)
+	marked := len(p.markers) == 0
+	for i, f := range fields {
+		if index[i] < 0 {
+			return false
+		}
+		for _, m := range p.markers {
+			if f == m {
+				marked = true
+			}
+		}
+	}
+	return marked
+}
+
+// DetectProduct 根据元数据中的字段识别产品类型
+func DetectProduct(fields []string) Product {
+	for _, p := range products {
+		if p.matches(fields) {
+			return p.product
+		}
+	}
+	return ProductCustom
+}
+
+// checkProduct 检查 reader 是否包含期望产品的全部必需字段, 允许额外的字段, want 为空时不检查
+func checkProduct(r *reader, want Product) error {
+	for _, p := range products {
+		if p.product != want {
+			continue
+		}
+		if missing := p.missing(r.meta.Fields); len(missing) > 0 {
+			return &ProductError{Want: want, Got: DetectProduct(r.meta.Fields), Fields: r.meta.Fields, Missing: missing}
+		}
+	}
+	return nil
+}
+
+// OpenAuto 加载数据库文件, 根据字段识别产品类型并返回对应的实例:
+// *City、*District、*IDC、*BaseStation、*Risk、*ASN 或 *Custom
+func OpenAuto(name string) (Database, Product, error) {
+	r, err := newReader(name, nil)
+	if err != nil {
+		return nil, "", msgInit.wrap(err, "ipdb")
+	}
+	return openDetected(r)
+}
+
+// OpenAutoBytes 从字节数据加载数据库并识别产品类型
+func OpenAutoBytes(bs []byte) (Database, Product, error) {
+	r, err := newReaderFromBytes(bs, nil)
+	if err != nil {
+		return nil, "", msgInitBytes.wrap(err, "ipdb")
+	}
+	return openDetected(r)
+}
+
+func openDetected(r *reader) (Database, Product, error) {
+	product := DetectProduct(r.meta.Fields)
+
+	var db Database
+	var err error
+	switch product {
+	case ProductCity:
+		db, err = newCityDB(r)
+	case ProductDistrict:
+		db, err = newDistrictDB(r)
+	case ProductIDC:
+		db, err = newIDCDB(r)
+	case ProductBaseStation:
+		db, err = newBaseStationDB(r)
+	case ProductRisk:
+		db, err = newRiskDB(r)
+	case ProductASN:
+		db = newTypedDB[ASNInfo](r)
+	default:
+		db = newTypedDB[struct{}](r)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return db, product, nil
+}
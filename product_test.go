@@ -0,0 +1,110 @@
+package ipdb_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectProduct(t *testing.T) {
+	for product, fields := range productFields {
+		assert.Equal(t, product, ipdb.DetectProduct(fields), "%v", fields)
+	}
+	assert.Equal(t, ipdb.ProductCity, ipdb.DetectProduct([]string{"country_name", "region_name", "city_name"}))
+}
+
+func TestOpenAuto(t *testing.T) {
+	for product, fields := range productFields {
+		d, got, err := ipdb.OpenAutoBytes(buildProductDB(t, fields...))
+		require.NoError(t, err)
+		assert.Equal(t, product, got)
+
+		switch product {
+		case ipdb.ProductCity:
+			assert.IsType(t, &ipdb.City{}, d)
+		case ipdb.ProductDistrict:
+			assert.IsType(t, &ipdb.District{}, d)
+		case ipdb.ProductIDC:
+			assert.IsType(t, &ipdb.IDC{}, d)
+		case ipdb.ProductBaseStation:
+			assert.IsType(t, &ipdb.BaseStation{}, d)
+		case ipdb.ProductRisk:
+			assert.IsType(t, &ipdb.Risk{}, d)
+		case ipdb.ProductASN:
+			asn := d.(*ipdb.ASN)
+			info, err := asn.FindInfo("1.2.3.4", "EN")
+			require.NoError(t, err)
+			assert.Equal(t, "EN:org", info.Org)
+		case ipdb.ProductCustom:
+			m, err := d.(*ipdb.Custom).FindMap("1.2.3.4", "CN")
+			require.NoError(t, err)
+			assert.Equal(t, "CN:weather", m["weather"])
+		}
+	}
+
+	d, product, err := ipdb.OpenAuto(TEST_DB_PATH)
+	require.NoError(t, err)
+	assert.Equal(t, ipdb.ProductCity, product)
+	assert.IsType(t, &ipdb.City{}, d)
+}
+
+func TestProductMismatch(t *testing.T) {
+	idc := buildProductDB(t, productFields[ipdb.ProductIDC]...)
+
+	_, err := ipdb.NewDistrictFromBytes(idc)
+	assert.ErrorIs(t, err, ipdb.ErrProductMismatch)
+	var pe *ipdb.ProductError
+	if assert.ErrorAs(t, err, &pe) {
+		assert.Equal(t, ipdb.ProductDistrict, pe.Want)
+		assert.Equal(t, ipdb.ProductIDC, pe.Got)
+		assert.Equal(t, []string{"district_name"}, pe.Missing)
+	}
+
+	_, err = ipdb.NewRiskFromBytes(buildTestDB(t))
+	assert.ErrorIs(t, err, ipdb.ErrProductMismatch)
+
+	// Reload 到缺少必需字段的数据库失败, 继续使用原数据库
+	name := filepath.Join(t.TempDir(), "risk.ipdb")
+	require.NoError(t, os.WriteFile(name, buildProductDB(t, productFields[ipdb.ProductRisk]...), 0o644))
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+	assert.ErrorIs(t, city.Reload(name), ipdb.ErrProductMismatch)
+	info, err := city.FindInfo("1.2.3.4", "EN")
+	require.NoError(t, err)
+	assert.Equal(t, "Beijing", info.CityName)
+}
+
+func TestProductExtraFields(t *testing.T) {
+	// 构造函数只检查必需字段, 额外的字段和厂商自定义列不影响加载
+	for _, fields := range [][]string{
+		{"country_name", "region_name", "city_name", "weather"},
+		{"country_name", "region_name", "city_name", "district_name"},
+		productFields[ipdb.ProductIDC],
+	} {
+		city, err := ipdb.NewCityFromBytes(buildProductDB(t, fields...))
+		require.NoError(t, err, "%v", fields)
+		info, err := city.FindInfo("1.2.3.4", "EN")
+		require.NoError(t, err)
+		assert.Equal(t, "EN:city_name", info.CityName)
+	}
+
+	district, err := ipdb.NewDistrictFromBytes(buildProductDB(t, "country_name", "region_name", "city_name", "district_name", "weather"))
+	require.NoError(t, err)
+	info, err := district.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "CN:district_name", info.DistrictName)
+
+	// OpenAuto 仍然精确识别, 无法对应到记录结构的字段组合识别为 custom
+	_, product, err := ipdb.OpenAutoBytes(buildProductDB(t, "country_name", "region_name", "city_name", "weather"))
+	require.NoError(t, err)
+	assert.Equal(t, ipdb.ProductCustom, product)
+
+	// 缺少 region_name、city_name 时仍然拒绝
+	city, err := ipdb.NewCityFromBytes(buildProductDB(t, "country_name", "idc"))
+	assert.ErrorIs(t, err, ipdb.ErrProductMismatch)
+	assert.Nil(t, city)
+}
@@ -0,0 +1,41 @@
+package ipdb
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// RangeItem 范围查询结果中的一个网络及其记录
+type RangeItem[T any] struct {
+	Network netip.Prefix
+	Info    *T
+}
+
+// findRange 在同一快照中遍历 prefix 范围内的网络, 按地址顺序返回
+func findRange[T any](ctx context.Context, db *database, prefix netip.Prefix, language string, decode decodeFunc[T]) ([]RangeItem[T], error) {
+	s, err := db.acquire()
+	if err != nil {
+		return nil, db.lookupError(prefix.String(), language, err)
+	}
+	defer s.reader.release()
+
+	r := s.reader
+	var items []RangeItem[T]
+	err = r.walkPrefixRecords(ctx, prefix, language, func(network *net.IPNet, data []string) bool {
+		items = append(items, RangeItem[T]{Network: toPrefix(network), Info: decode(r, data)})
+		return true
+	})
+	if err != nil {
+		return nil, s.lookupError(prefix.String(), language, err)
+	}
+
+	return items, nil
+}
+
+// toPrefix 将 walk 返回的网络转换为 netip.Prefix, IPv4 网络转换为 IPv4 前缀
+func toPrefix(network *net.IPNet) netip.Prefix {
+	addr, _ := netip.AddrFromSlice(network.IP)
+	ones, _ := network.Mask.Size()
+	return netip.PrefixFrom(addr, ones)
+}
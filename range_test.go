@@ -0,0 +1,94 @@
+package ipdb_test
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCity_FindRange(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	items, err := city.FindRange(netip.MustParsePrefix("1.0.0.0/8"), "EN")
+	require.NoError(t, err)
+	var addrs uint64
+	found := make(map[string]string)
+	for i, item := range items {
+		assert.True(t, item.Network.Addr().Is4())
+		addrs += 1 << uint(32-item.Network.Bits())
+		found[item.Network.String()] = item.Info.CountryName
+		if i > 0 {
+			assert.True(t, items[i-1].Network.Addr().Less(item.Network.Addr()))
+		}
+	}
+	assert.Equal(t, uint64(1<<24), addrs)
+	assert.Equal(t, "China", found["1.2.3.0/24"])
+	assert.Equal(t, "Australia", found["1.0.0.0/15"])
+
+	tests := []struct {
+		prefix  string
+		network string
+		country string
+	}{
+		{"1.2.3.128/25", "1.2.3.128/25", "China"}, // 位于更大的网络中
+		{"1.2.3.4/16", "1.2.0.0/23", "Australia"}, // 未对齐的前缀
+		{"::ffff:1.2.3.0/120", "1.2.3.0/24", "China"},
+		{"2001::/16", "2001:db8::/32", "Reserved"},
+	}
+	for _, tt := range tests {
+		items, err := city.FindRange(netip.MustParsePrefix(tt.prefix), "EN")
+		require.NoError(t, err, tt.prefix)
+		require.NotEmpty(t, items, tt.prefix)
+		assert.Equal(t, tt.network, items[0].Network.String(), tt.prefix)
+		assert.Equal(t, tt.country, items[0].Info.CountryName, tt.prefix)
+	}
+
+	items, err = city.FindRange(netip.MustParsePrefix("8.0.0.0/8"), "EN")
+	require.NoError(t, err)
+	assert.Empty(t, items)
+
+	_, err = city.FindRange(netip.Prefix{}, "EN")
+	assert.ErrorIs(t, err, ipdb.ErrIPFormat)
+	_, err = city.FindRange(netip.MustParsePrefix("1.0.0.0/8"), "JP")
+	assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = city.FindRangeContext(ctx, netip.MustParsePrefix("1.0.0.0/8"), "EN")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestCity_FindRangeMatchesWalk(t *testing.T) {
+	var walked []string
+	err := db.Walk("CN", func(network *net.IPNet, info *ipdb.CityInfo) bool {
+		if network.IP.To4() != nil && network.IP[0] == 36 {
+			walked = append(walked, network.String())
+		}
+		return true
+	})
+	require.NoError(t, err)
+
+	items, err := db.FindRange(netip.MustParsePrefix("36.0.0.0/8"), "CN")
+	require.NoError(t, err)
+	require.Len(t, items, len(walked))
+	for i, item := range items {
+		assert.Equal(t, walked[i], item.Network.String())
+	}
+}
+
+func TestRisk_FindRange(t *testing.T) {
+	risk, err := ipdb.NewRiskFromBytes(buildProductDB(t, productFields[ipdb.ProductRisk]...))
+	require.NoError(t, err)
+
+	items, err := risk.FindRange(netip.MustParsePrefix("2001:db8::/48"))
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "2001:db8::/48", items[0].Network.String())
+	assert.Equal(t, "CN:behavior", items[0].Info.Behavior)
+}
@@ -1,15 +1,22 @@
 package ipdb
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/json"
-	"errors"
-	"io/ioutil"
+	"fmt"
+	"io"
+	"io/fs"
 	"net"
+	"net/netip"
 	"os"
 	"reflect"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,18 +25,6 @@ const (
 	IPv6 = 0x02
 )
 
-var (
-	ErrFileSize          = errors.New("IP数据库文件大小错误")
-	ErrMetaData          = errors.New("IP数据库元数据错误")
-	ErrReadFull          = errors.New("IP数据库读取错误")
-	ErrDatabase          = errors.New("数据库错误")
-	ErrIPFormat          = errors.New("IP地址格式错误")
-	ErrNoSupportLanguage = errors.New("不支持该语言")
-	ErrNoSupportIPv4     = errors.New("不支持IPv4")
-	ErrNoSupportIPv6     = errors.New("不支持IPv6")
-	ErrDataNotExists     = errors.New("数据不存在")
-)
-
 type MetaData struct {
 	Build     int64          `json:"build"`
 	IPVersion uint16         `json:"ip_version"`
@@ -39,8 +34,11 @@ type MetaData struct {
 	Fields    []string       `json:"fields"`
 }
 
+// readerGeneration 为每个 reader 分配递增的编号
+var readerGeneration atomic.Uint64
+
 type reader struct {
-	sync.RWMutex
+	gen       uint64 // 创建时分配的编号, 缓存条目据此区分所属的 reader
 	fileSize  int
 	nodeCount int
 	v4offset  int
@@ -48,11 +46,31 @@ type reader struct {
 	meta MetaData
 	data []byte
 
-	refType map[string]string
-	cache   sync.Map
+	fieldIndex []int // 记录中每个字段对应的结构体字段下标, -1 表示无对应字段
+
+	mapped    []byte      // mmap 模式下映射的整个文件, 否则为 nil
+	file      os.FileInfo // mmap 模式下映射的文件, 用于识别原地修改的文件
+	closeOnce sync.Once
+	closeErr  error
+
+	users   atomic.Int64 // 正在使用 reader 的查询数
+	retired atomic.Bool  // 已不属于任何快照, 最后一个查询结束后释放
+}
+
+// openReader 按指定模式加载数据库文件, ctx 取消时中止读取和校验
+func openReader(ctx context.Context, name string, obj interface{}, mmap bool) (*reader, error) {
+	if mmap {
+		return newReaderMmapContext(ctx, name, obj)
+	}
+	return newReaderContext(ctx, name, obj)
 }
 
 func newReader(name string, obj interface{}) (*reader, error) {
+	return newReaderContext(context.Background(), name, obj)
+}
+
+// newReaderContext 将数据库文件读入堆内存, 分块读取以便及时响应 ctx 的取消
+func newReaderContext(ctx context.Context, name string, obj interface{}) (*reader, error) {
 	var err error
 	var fileInfo os.FileInfo
 	fileInfo, err = os.Stat(name)
@@ -63,131 +81,461 @@ func newReader(name string, obj interface{}) (*reader, error) {
 	if fileSize < 4 {
 		return nil, ErrFileSize
 	}
-	body, err := ioutil.ReadFile(name)
+
+	f, err := os.Open(name)
 	if err != nil {
-		return nil, ErrReadFull
+		return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
 	}
+	defer f.Close()
 
-	return initBytes(body, fileSize, obj)
+	head := make([]byte, len(zstdMagic))
+	if n, _ := f.ReadAt(head, 0); isCompressed(head[:n]) {
+		body, err := readDatabase(contextReader(ctx, f), 0)
+		if err != nil {
+			return nil, canceled(ctx, err)
+		}
+		return loadBytes(ctx, body, obj)
+	}
+
+	body := make([]byte, fileSize)
+	if _, err := io.ReadFull(contextReader(ctx, f), body); err != nil {
+		return nil, canceled(ctx, fmt.Errorf("%w: %w", ErrReadFull, err))
+	}
+
+	return initBytes(ctx, body, fileSize, obj)
+}
+
+func newReaderMmap(name string, obj interface{}) (*reader, error) {
+	return newReaderMmapContext(context.Background(), name, obj)
+}
+
+// newReaderMmapContext 通过 mmap 加载数据库文件, 多个进程可共享同一份页缓存.
+// 被替换的 reader 在不再被引用后由 finalizer 解除映射, 也可以调用 close 立即释放.
+// 映射期间文件不能被原地截断或改写, 否则读取映射内存会触发 SIGBUS 或读到不完整的数据,
+// 更新文件时应写入临时文件后用 os.Rename 替换.
+func newReaderMmapContext(ctx context.Context, name string, obj interface{}) (*reader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	fileSize := int(fileInfo.Size())
+	if fileSize < 4 {
+		return nil, ErrFileSize
+	}
+
+	// 压缩文件无法直接映射, 解压到堆内存中加载
+	head := make([]byte, len(zstdMagic))
+	if n, _ := f.ReadAt(head, 0); isCompressed(head[:n]) {
+		body, err := readDatabase(contextReader(ctx, f), 0)
+		if err != nil {
+			return nil, canceled(ctx, err)
+		}
+		return loadBytes(ctx, body, obj)
+	}
+
+	body, err := mmapFile(f, fileSize)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+	}
+
+	db, err := initBytes(ctx, body, fileSize, obj)
+	if err != nil {
+		munmap(body)
+		return nil, err
+	}
+	db.mapped = body
+	db.file = fileInfo
+	runtime.SetFinalizer(db, (*reader).close)
+
+	return db, nil
+}
+
+// close 解除 mmap 映射, 之后不能再使用该 reader
+func (db *reader) close() error {
+	db.closeOnce.Do(func() {
+		if db.mapped != nil {
+			db.closeErr = munmap(db.mapped)
+		}
+	})
+	return db.closeErr
+}
+
+// acquire 在查询开始时登记, reader 已退役时返回 false, 之后不能再访问 data
+func (db *reader) acquire() bool {
+	db.users.Add(1)
+	if db.retired.Load() {
+		db.release()
+		return false
+	}
+	return true
+}
+
+// release 在查询结束时注销, 退役后的最后一个查询负责释放 mmap
+func (db *reader) release() {
+	if db.users.Add(-1) == 0 && db.retired.Load() {
+		db.close()
+	}
+}
+
+// retire 标记 reader 不再被任何快照引用, 没有进行中的查询时立即释放并返回释放的结果
+func (db *reader) retire() error {
+	db.retired.Store(true)
+	if db.users.Load() == 0 {
+		return db.close()
+	}
+	return nil
 }
 
 func newReaderFromBytes(body []byte, obj interface{}) (*reader, error) {
+	return loadBytes(context.Background(), body, obj)
+}
+
+// loadBytes 从字节数据加载数据库, 压缩数据先解压
+func loadBytes(ctx context.Context, body []byte, obj interface{}) (*reader, error) {
+	if isCompressed(body) {
+		var err error
+		if body, err = readDatabase(bytes.NewReader(body), 0); err != nil {
+			return nil, err
+		}
+	}
 	if len(body) < 4 {
 		return nil, ErrFileSize
 	}
-	return initBytes(body, len(body), obj)
+	return initBytes(ctx, body, len(body), obj)
+}
+
+// newReaderFromReader 从 io.Reader 读取数据库, maxSize 大于 0 时限制读取的最大字节数
+func newReaderFromReader(rd io.Reader, maxSize int64, obj interface{}) (*reader, error) {
+	body, err := readDatabase(rd, maxSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReaderFromBytes(body, obj)
+}
+
+// newReaderFromReaderAt 从 io.ReaderAt 读取 size 字节的数据库
+func newReaderFromReaderAt(rd io.ReaderAt, size int64, obj interface{}) (*reader, error) {
+	if size < 4 || int64(int(size)) != size {
+		return nil, ErrFileSize
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(io.NewSectionReader(rd, 0, size), body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrReadFull, err)
+	}
+
+	return newReaderFromBytes(body, obj)
+}
+
+// newReaderFromFS 从 fs.FS 中读取数据库文件, 可用于 embed.FS
+func newReaderFromFS(fsys fs.FS, name string, obj interface{}) (*reader, error) {
+	body, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newReaderFromBytes(body, obj)
+}
+
+func initBytes(ctx context.Context, body []byte, fileSize int, obj interface{}) (*reader, error) {
+	db, err := parseBytes(body, fileSize, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.validate(ctx); err != nil {
+		return nil, err
+	}
+
+	db.v4offset = db.ipv4Offset()
+
+	return db, nil
+}
+
+// parseBytes 解析文件头和元数据, 不检查树结构
+func parseBytes(body []byte, fileSize int, obj interface{}) (*reader, error) {
+	if fileSize != len(body) {
+		return nil, ErrFileSize
+	}
+	db, err := parseHeader(body, obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(db.data) != db.meta.TotalSize {
+		return nil, ErrFileSize
+	}
+	return db, nil
 }
 
-func initBytes(body []byte, fileSize int, obj interface{}) (*reader, error) {
+// parseHeader 解析文件头和元数据, 数据区可以长于元数据声明的 total_size
+func parseHeader(body []byte, obj interface{}) (*reader, error) {
+	if len(body) < 4 {
+		return nil, ErrFileSize
+	}
+
 	var meta MetaData
-	metaLength := int(binary.BigEndian.Uint32(body[0:4]))
-	if fileSize < (4 + metaLength) {
+	metaLength := int64(binary.BigEndian.Uint32(body[0:4]))
+	if int64(len(body)) < 4+metaLength {
 		return nil, ErrFileSize
 	}
 	if err := json.Unmarshal(body[4:4+metaLength], &meta); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrMetaData, err)
 	}
 	if len(meta.Languages) == 0 || len(meta.Fields) == 0 {
 		return nil, ErrMetaData
 	}
-	if fileSize != (4 + metaLength + meta.TotalSize) {
+	if int64(len(body)) < 4+metaLength+int64(meta.TotalSize) {
 		return nil, ErrFileSize
 	}
-
-	var dm map[string]string
-	if obj != nil {
-		t := reflect.TypeOf(obj).Elem()
-		dm = make(map[string]string, t.NumField())
-		for i := 0; i < t.NumField(); i++ {
-			k := t.Field(i).Tag.Get("json")
-			dm[k] = t.Field(i).Name
+	if meta.NodeCount <= 0 || meta.NodeCount > meta.TotalSize/8 {
+		return nil, ErrMetaData
+	}
+	for _, off := range meta.Languages {
+		if off < 0 {
+			return nil, ErrMetaData
 		}
 	}
 
 	db := &reader{
-		fileSize:  fileSize,
+		gen:       readerGeneration.Add(1),
+		fileSize:  len(body),
 		nodeCount: meta.NodeCount,
 
-		meta:    meta,
-		refType: dm,
+		meta:       meta,
+		fieldIndex: fieldIndex(obj, meta.Fields),
 
 		data: body[4+metaLength:],
 	}
 
-	if db.v4offset == 0 {
-		node := 0
-		for i := 0; i < 96 && node < db.nodeCount; i++ {
-			if i >= 80 {
-				node = db.readNode(node, 1)
-			} else {
-				node = db.readNode(node, 0)
-			}
+	return db, nil
+}
+
+// ipv4Offset 沿 ::ffff:0:0/96 路径查找 IPv4 子树的起始节点
+func (db *reader) ipv4Offset() int {
+	node := 0
+	for i := 0; i < 96 && node < db.nodeCount; i++ {
+		if i >= 80 {
+			node = db.readNode(node, 1)
+		} else {
+			node = db.readNode(node, 0)
 		}
-		db.v4offset = node
 	}
+	return node
+}
 
-	return db, nil
+// FormatError 描述数据库文件中的结构错误, 可以用 errors.Is(err, ErrDatabase) 判断
+type FormatError struct {
+	Node   int    // 出错的节点下标
+	Offset int    // 出错位置在数据区中的偏移
+	Reason string // 错误原因
 }
 
-func (db *reader) Find(addr, language string) ([]string, error) {
-	db.RLock()
-	defer db.RUnlock()
-	return db.find1(addr, language)
+func (e *FormatError) Error() string {
+	return ErrDatabase.Error() + ": " + msgFormat.format(e.Node, e.Offset, e.Reason)
 }
 
-func (db *reader) FindMap(addr, language string) (map[string]string, error) {
-	db.RLock()
-	defer db.RUnlock()
+func (e *FormatError) Unwrap() error {
+	return ErrDatabase
+}
 
-	if val, ok := db.cache.Load(addr + language); ok {
-		return val.(map[string]string), nil
+// validate 在加载时检查树结构: 节点值不越界, 记录完整位于数据区内, 且不存在环,
+// 之后的 readNode/resolve 就不会因为恶意或截断的文件而越界. 大型数据库的检查较慢, 每隔一段检查 ctx 是否已取消.
+func (db *reader) validate(ctx context.Context) error {
+	n := db.nodeCount
+	for i := 0; i < n*2; i++ {
+		if i%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		v := int(binary.BigEndian.Uint32(db.data[i*4 : i*4+4]))
+		if v <= n {
+			continue
+		}
+		resolved := v - n + n*8
+		if resolved+2 > len(db.data) {
+			return &FormatError{Node: i / 2, Offset: i * 4, Reason: msgRecordOffset.String()}
+		}
+		size := int(binary.BigEndian.Uint16(db.data[resolved : resolved+2]))
+		if resolved+2+size > len(db.data) {
+			return &FormatError{Node: i / 2, Offset: resolved, Reason: msgRecordLength.String()}
+		}
 	}
 
-	data, err := db.find1(addr, language)
-	if err != nil {
-		return nil, err
+	// 深度优先遍历检查树结构, state: 0 未访问, 1 在当前路径上, 2 已完成.
+	// 每个节点只能被一个父节点引用, 否则后续遍历的次数可能随深度指数增长
+	type frame struct {
+		node  int
+		child int
+	}
+	state := make([]uint8, n)
+	stack := []frame{{node: 0}}
+	state[0] = 1
+	for steps := 0; len(stack) > 0; steps++ {
+		if steps%cancelCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		top := &stack[len(stack)-1]
+		if top.child == 2 {
+			state[top.node] = 2
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := db.readNode(top.node, top.child)
+		top.child++
+		if next >= n {
+			continue
+		}
+		switch state[next] {
+		case 1:
+			return &FormatError{Node: top.node, Offset: top.node * 8, Reason: msgNodeCycle.String()}
+		case 2:
+			return &FormatError{Node: top.node, Offset: top.node * 8, Reason: msgNodeParents.format(next)}
+		}
+		state[next] = 1
+		stack = append(stack, frame{node: next})
 	}
 
-	info := make(map[string]string, len(db.meta.Fields))
-	for k, v := range data {
-		info[db.meta.Fields[k]] = v
+	return nil
+}
+
+// fieldIndex 按标签将数据库字段映射到结构体字段下标, ipdb 标签优先于 json 标签, 标签为 "-" 的字段忽略
+func fieldIndex(obj interface{}, fields []string) []int {
+	if obj == nil {
+		return nil
 	}
 
-	db.cache.Store(addr+language, info)
+	t := reflect.TypeOf(obj).Elem()
+	dm := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		k, ok := f.Tag.Lookup("ipdb")
+		if !ok {
+			k = f.Tag.Get("json")
+		}
+		k = strings.Split(k, ",")[0]
+		if k == "-" {
+			continue
+		}
+		if k == "" {
+			k = f.Name
+		}
+		if _, ok := dm[k]; !ok {
+			dm[k] = i
+		}
+	}
 
-	return info, nil
+	index := make([]int, len(fields))
+	for i, f := range fields {
+		if k, ok := dm[f]; ok {
+			index[i] = k
+		} else {
+			index[i] = -1
+		}
+	}
+	return index
+}
+
+// fill 按字段顺序将记录值写入 obj 指向的结构体, 非字符串字段按数字或 JSON 解析
+func (db *reader) fill(obj interface{}, data []string) {
+	val := reflect.ValueOf(obj).Elem()
+	for i, v := range data {
+		if i >= len(db.fieldIndex) || db.fieldIndex[i] < 0 {
+			continue
+		}
+
+		field := val.Field(db.fieldIndex[i])
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(v)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if n, err := strconv.ParseInt(v, 10, field.Type().Bits()); err == nil {
+				field.SetInt(n)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if n, err := strconv.ParseUint(v, 10, field.Type().Bits()); err == nil {
+				field.SetUint(n)
+			}
+		case reflect.Float32, reflect.Float64:
+			if n, err := strconv.ParseFloat(v, field.Type().Bits()); err == nil {
+				field.SetFloat(n)
+			}
+		case reflect.Bool:
+			if b, ok := parseBool(v); ok {
+				field.SetBool(b)
+			}
+		default:
+			if v == "" {
+				continue
+			}
+			ptr := reflect.New(field.Type())
+			if err := json.Unmarshal([]byte(v), ptr.Interface()); err == nil {
+				field.Set(ptr.Elem())
+			}
+		}
+	}
+}
+
+// parseBool 解析布尔字段, 除 strconv.ParseBool 支持的写法外还接受 Y/N、yes/no
+func parseBool(v string) (bool, bool) {
+	switch strings.ToLower(v) {
+	case "y", "yes":
+		return true, true
+	case "n", "no":
+		return false, true
+	}
+	b, err := strconv.ParseBool(v)
+	return b, err == nil
 }
 
 func (db *reader) find0(addr string) ([]byte, error) {
+	node, _, err := db.locate(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.resolve(node)
+}
+
+// locate 查找地址对应的叶子节点, 同时返回命中的网络前缀
+func (db *reader) locate(addr string) (int, *net.IPNet, error) {
 	var err error
-	var node int
+	var node, prefix int
+	var ip net.IP
 	ipv := net.ParseIP(addr)
-	if ip := ipv.To4(); ip != nil {
+	if ip = ipv.To4(); ip != nil {
 		if !db.IsIPv4Support() {
-			return nil, ErrNoSupportIPv4
+			return -1, nil, ErrNoSupportIPv4
 		}
 
-		node, err = db.search(ip, 32)
-	} else if ip := ipv.To16(); ip != nil {
+		node, prefix, err = db.search(ip, 32)
+	} else if ip = ipv.To16(); ip != nil {
 		if !db.IsIPv6Support() {
-			return nil, ErrNoSupportIPv6
+			return -1, nil, ErrNoSupportIPv6
 		}
 
-		node, err = db.search(ip, 128)
+		node, prefix, err = db.search(ip, 128)
 	} else {
-		return nil, ErrIPFormat
+		return -1, nil, ErrIPFormat
 	}
 
 	if err != nil || node < 0 {
-		return nil, err
+		return -1, nil, err
 	}
 
-	return db.resolve(node)
+	mask := net.CIDRMask(prefix, len(ip)*8)
+	return node, &net.IPNet{IP: ip.Mask(mask), Mask: mask}, nil
 }
 
 func (db *reader) find1(addr, language string) ([]string, error) {
-	off, ok := db.meta.Languages[language]
-	if !ok {
+	if !db.hasLanguage(language) {
 		return nil, ErrNoSupportLanguage
 	}
 
@@ -196,31 +544,109 @@ func (db *reader) find1(addr, language string) ([]string, error) {
 		return nil, err
 	}
 
-	str := string(body)
-	tmp := strings.Split(str, "\t")
+	return db.split(body, language)
+}
 
-	if (off + len(db.meta.Fields)) > len(tmp) {
-		return nil, ErrDatabase
+// record 返回叶子节点对应记录中指定语言的字段值
+func (db *reader) record(node int, language string) ([]string, error) {
+	if !db.hasLanguage(language) {
+		return nil, ErrNoSupportLanguage
 	}
 
-	return tmp[off : off+len(db.meta.Fields)], nil
+	body, err := db.resolve(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.split(body, language)
+}
+
+// split 截取记录中指定语言的字段值, 只做一次字符串转换
+func (db *reader) split(body []byte, language string) ([]string, error) {
+	off, ok := db.meta.Languages[language]
+	if !ok {
+		if strings.Contains(language, languageSeparator) {
+			return db.splitChain(body, language)
+		}
+		return nil, ErrNoSupportLanguage
+	}
+
+	n := len(db.meta.Fields)
+	start := 0
+	for k := 0; k < off; k++ {
+		i := bytes.IndexByte(body[start:], '\t')
+		if i < 0 {
+			return nil, ErrDatabase
+		}
+		start += i + 1
+	}
+	end := start
+	for k := 1; k < n; k++ {
+		i := bytes.IndexByte(body[end:], '\t')
+		if i < 0 {
+			return nil, ErrDatabase
+		}
+		end += i + 1
+	}
+	if i := bytes.IndexByte(body[end:], '\t'); i >= 0 {
+		end += i
+	} else {
+		end = len(body)
+	}
+
+	str := string(body[start:end])
+	values := make([]string, n)
+	for k := 0; k < n-1; k++ {
+		i := strings.IndexByte(str, '\t')
+		values[k] = str[:i]
+		str = str[i+1:]
+	}
+	values[n-1] = str
+
+	// body 可能指向 mmap 内存, 确保使用期间 reader 不会被回收
+	runtime.KeepAlive(db)
+
+	return values, nil
+}
+
+// toMap 将字段值按字段名转换为映射
+func (db *reader) toMap(data []string) map[string]string {
+	info := make(map[string]string, len(db.meta.Fields))
+	for k, v := range data {
+		info[db.meta.Fields[k]] = v
+	}
+	return info
 }
 
-func (db *reader) search(ip net.IP, bitCount int) (int, error) {
+// search 沿树查找, 返回叶子节点及查找时消耗的位数 (即命中网络的前缀长度)
+func (db *reader) search(ip net.IP, bitCount int) (int, int, error) {
 	node := 0
 	if bitCount == 32 {
 		node = db.v4offset
 	}
 
-	for i := 0; i < bitCount && node < db.nodeCount; i++ {
+	i := 0
+	for ; i < bitCount && node < db.nodeCount; i++ {
 		node = db.readNode(node, int((ip[i>>3]>>(7-(i&7)))&1))
 	}
 
 	if node > db.nodeCount {
-		return node, nil
+		return node, i, nil
 	}
 
-	return -1, ErrDataNotExists
+	return -1, 0, ErrDataNotExists
+}
+
+// descend 从 path[from] 开始继续沿树查找, 经过的节点依次写入 path[from+1:], 返回停止时的节点和消耗的位数.
+// path[d] 为消耗 d 位后到达的节点, 长度至少为 bitCount+1.
+func (db *reader) descend(ip []byte, bitCount int, path []int, from int) (int, int) {
+	node := path[from]
+	i := from
+	for ; i < bitCount && node < db.nodeCount; i++ {
+		node = db.readNode(node, int((ip[i>>3]>>(7-(i&7)))&1))
+		path[i+1] = node
+	}
+	return node, i
 }
 
 func (db *reader) readNode(node, index int) int {
@@ -230,7 +656,7 @@ func (db *reader) readNode(node, index int) int {
 
 func (db *reader) resolve(node int) ([]byte, error) {
 	resolved := node - db.nodeCount + db.nodeCount*8
-	if resolved >= db.fileSize {
+	if node <= db.nodeCount || resolved+2 > len(db.data) {
 		return nil, ErrDatabase
 	}
 
@@ -263,6 +689,57 @@ func (db *reader) Languages() []string {
 	return ls
 }
 
-func (db *reader) ClearCache() {
-	db.cache = sync.Map{}
+// locateAddr 与 locate 相同, 直接使用 netip.Addr 避免重复解析字符串
+func (db *reader) locateAddr(addr netip.Addr) (int, netip.Prefix, error) {
+	if !addr.IsValid() {
+		return -1, netip.Prefix{}, ErrIPFormat
+	}
+
+	var err error
+	var node, prefix int
+	addr = addr.Unmap().WithZone("")
+	if addr.Is4() {
+		if !db.IsIPv4Support() {
+			return -1, netip.Prefix{}, ErrNoSupportIPv4
+		}
+
+		ip := addr.As4()
+		node, prefix, err = db.search(ip[:], 32)
+	} else {
+		if !db.IsIPv6Support() {
+			return -1, netip.Prefix{}, ErrNoSupportIPv6
+		}
+
+		ip := addr.As16()
+		node, prefix, err = db.search(ip[:], 128)
+	}
+
+	if err != nil || node < 0 {
+		return -1, netip.Prefix{}, err
+	}
+
+	network, err := addr.Prefix(prefix)
+	if err != nil {
+		return -1, netip.Prefix{}, err
+	}
+	return node, network, nil
+}
+
+// findAddr 查找 netip.Addr 对应的字段值及命中的网络
+func (db *reader) findAddr(addr netip.Addr, language string) ([]string, netip.Prefix, error) {
+	if !db.hasLanguage(language) {
+		return nil, netip.Prefix{}, ErrNoSupportLanguage
+	}
+
+	node, network, err := db.locateAddr(addr)
+	if err != nil {
+		return nil, netip.Prefix{}, err
+	}
+
+	data, err := db.record(node, language)
+	if err != nil {
+		return nil, netip.Prefix{}, err
+	}
+
+	return data, network, nil
 }
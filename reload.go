@@ -0,0 +1,144 @@
+package ipdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+)
+
+var (
+	// ErrReloadCheck 新数据库未通过重新加载时的校验, 原数据库保持不变
+	ErrReloadCheck = kindError("new database failed the reload check", "新数据库未通过校验", ErrDatabase)
+	// ErrReloadInPlace mmap 模式下重新加载的仍是正在映射的文件, 文件需要通过重命名整体替换
+	ErrReloadInPlace = kindError("mmap database file must be replaced by renaming a new file", "mmap 模式下数据库文件必须通过重命名整体替换", ErrInvalidInput)
+	// ErrNoRollback 没有可以回滚的数据库
+	ErrNoRollback = kindError("no previous database to roll back to", "没有可回滚的数据库", ErrInvalidInput)
+)
+
+// ReloadOptions 重新加载时对新数据库的校验. 校验全部通过后才会替换当前数据库.
+type ReloadOptions struct {
+	// SampleIPs 冒烟测试地址, 每个地址在新数据库中都必须有记录
+	SampleIPs []string
+	// Language 查询 SampleIPs 使用的语言, 为空时只检查地址能否命中记录
+	Language string
+	// Check 自定义校验, candidate 为尚未发布的新数据库, 返回错误时放弃重新加载.
+	// candidate 只能用于查询, 不要断言为 Reloader 或 Cacher 使用.
+	Check func(candidate Database) error
+}
+
+// ReloadReport 重新加载或回滚前后的数据库构建时间
+type ReloadReport struct {
+	OldBuild time.Time
+	NewBuild time.Time
+}
+
+// ReloadWithOptions 在后台加载并校验新的数据库文件, 通过后原子替换当前快照, 查询不会被阻塞.
+// 被替换的数据库保留一份, 可以调用 Rollback 恢复; 任何一步失败时当前数据库保持不变.
+// mmap 模式下当前和保留的数据库仍映射着旧文件, 新文件必须先写入临时文件再用 os.Rename 替换,
+// 原地改写正在映射的文件 (文件未变化) 时返回 ErrReloadInPlace.
+func (db *database) ReloadWithOptions(ctx context.Context, name string, opts ReloadOptions) (ReloadReport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ReloadReport{}, msgReload.wrap(ErrClosed)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return ReloadReport{}, msgFileAbsent.wrap(err)
+	}
+
+	s := db.snap.Load()
+	if db.isMapped(fi) {
+		return ReloadReport{}, msgReload.wrap(ErrReloadInPlace)
+	}
+	reader, err := openReader(ctx, name, db.obj, s.reader.mapped != nil)
+	if err != nil {
+		return ReloadReport{}, msgReload.wrap(err)
+	}
+	if err := db.check(ctx, reader, opts); err != nil {
+		reader.close()
+		return ReloadReport{}, msgReload.wrap(err)
+	}
+
+	next := newSnapshot(reader, s.cache, db.product)
+	db.publish(next)
+
+	return ReloadReport{OldBuild: s.reader.Build(), NewBuild: reader.Build()}, nil
+}
+
+// Rollback 恢复到最近一次重新加载之前的数据库, 只能回滚一次.
+// mmap 模式下保留的数据库映射着旧文件, 旧文件同样不能被原地改写;
+// 被回滚掉的数据库在进行中的查询结束后释放映射.
+func (db *database) Rollback() (ReloadReport, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.prev == nil {
+		return ReloadReport{}, ErrNoRollback
+	}
+
+	s := db.snap.Load()
+	prev := db.prev
+	db.snap.Store(&snapshot{reader: prev.reader, cache: s.cache, product: prev.product})
+	db.prev = nil
+	s.cache.Clear()
+	s.reader.retire()
+
+	return ReloadReport{OldBuild: s.reader.Build(), NewBuild: prev.reader.Build()}, nil
+}
+
+// publish 原子替换当前快照, 并保留被替换的快照用于回滚. 调用方需持有 mu.
+// 缓存条目记录了所属的 reader, 新旧快照可以共用同一个缓存.
+// 更早保留的快照不再被引用, 其 reader 在进行中的查询结束后释放映射.
+func (db *database) publish(next *snapshot) {
+	s := db.snap.Swap(next)
+	if db.prev != nil {
+		db.prev.reader.retire()
+	}
+	db.prev = s
+	s.cache.Clear() // 清理缓存
+}
+
+// check 检查新 reader 的产品类型, 查询冒烟测试地址, 并执行自定义校验
+func (db *database) check(ctx context.Context, r *reader, opts ReloadOptions) error {
+	if err := checkProduct(r, db.product); err != nil {
+		return err
+	}
+
+	for _, ip := range opts.SampleIPs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var err error
+		if opts.Language == "" {
+			_, _, err = r.locate(ip)
+		} else {
+			_, err = r.find1(ip, opts.Language)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrReloadCheck, ip, err)
+		}
+	}
+
+	if opts.Check != nil {
+		candidate := &database{obj: db.obj, product: db.product}
+		candidate.snap.Store(newSnapshot(r, noCache{}, db.product))
+		if err := opts.Check(candidate); err != nil {
+			return fmt.Errorf("%w: %w", ErrReloadCheck, err)
+		}
+	}
+
+	return ctx.Err()
+}
+
+// isMapped fi 是否为当前或保留的快照正在映射的文件. 调用方需持有 mu.
+func (db *database) isMapped(fi os.FileInfo) bool {
+	for _, s := range []*snapshot{db.snap.Load(), db.prev} {
+		if s != nil && s.reader.file != nil && os.SameFile(s.reader.file, fi) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,216 @@
+package ipdb_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// saveTestDB 将数据库写入临时目录中的 name 文件
+func saveTestDB(t *testing.T, name string, body []byte) string {
+	name = filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(name, body, 0o644))
+	return name
+}
+
+func TestReloadWithOptions(t *testing.T) {
+	old := saveTestDB(t, "old.ipdb", buildTestDB(t))
+	next := saveTestDB(t, "next.ipdb", buildTestDB(t, withBuild(1800000000), withCity("上海", "Shanghai")))
+	ctx := context.Background()
+
+	city, err := ipdb.NewCity(old)
+	require.NoError(t, err)
+
+	_, err = city.Rollback()
+	assert.ErrorIs(t, err, ipdb.ErrNoRollback)
+
+	// 冒烟测试失败时保持原数据库
+	_, err = city.ReloadWithOptions(ctx, next, ipdb.ReloadOptions{SampleIPs: []string{"1.2.3.4", "8.8.8.8"}})
+	assert.ErrorIs(t, err, ipdb.ErrReloadCheck)
+	assert.ErrorIs(t, err, ipdb.ErrDataNotExists)
+	assert.Contains(t, err.Error(), "8.8.8.8")
+
+	_, err = city.ReloadWithOptions(ctx, next, ipdb.ReloadOptions{SampleIPs: []string{"1.2.3.4"}, Language: "JP"})
+	assert.ErrorIs(t, err, ipdb.ErrNoSupportLanguage)
+
+	_, err = city.ReloadWithOptions(ctx, next, ipdb.ReloadOptions{
+		Check: func(candidate ipdb.Database) error {
+			m, err := candidate.FindMap("1.2.3.4", "CN")
+			if err != nil {
+				return err
+			}
+			if m["city_name"] != "北京" {
+				return errors.New("unexpected city " + m["city_name"])
+			}
+			return nil
+		},
+	})
+	assert.ErrorIs(t, err, ipdb.ErrReloadCheck)
+	assert.Contains(t, err.Error(), "上海")
+
+	corrupt := saveTestDB(t, "corrupt.ipdb", []byte("not a database"))
+	_, err = city.ReloadWithOptions(ctx, corrupt, ipdb.ReloadOptions{})
+	assert.ErrorIs(t, err, ipdb.ErrDatabase)
+
+	info, err := city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.CityName)
+	_, err = city.Rollback()
+	assert.ErrorIs(t, err, ipdb.ErrNoRollback)
+
+	report, err := city.ReloadWithOptions(ctx, next, ipdb.ReloadOptions{SampleIPs: []string{"1.2.3.4"}, Language: "CN"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1700000000), report.OldBuild.Unix())
+	assert.Equal(t, int64(1800000000), report.NewBuild.Unix())
+	info, err = city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "上海", info.CityName)
+
+	report, err = city.Rollback()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1800000000), report.OldBuild.Unix())
+	assert.Equal(t, int64(1700000000), report.NewBuild.Unix())
+	assert.Equal(t, int64(1700000000), city.BuildTime().Unix())
+	info, err = city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.CityName)
+
+	_, err = city.Rollback()
+	assert.ErrorIs(t, err, ipdb.ErrNoRollback)
+}
+
+func TestReloadRollbackMmap(t *testing.T) {
+	old := saveTestDB(t, "old.ipdb", buildTestDB(t))
+	next := saveTestDB(t, "next.ipdb", buildTestDB(t, withBuild(1800000000), withCity("上海", "Shanghai")))
+
+	city, err := ipdb.NewCityMmap(old)
+	require.NoError(t, err)
+	defer city.Close()
+
+	require.NoError(t, city.Reload(next))
+	_, err = city.Rollback()
+	require.NoError(t, err)
+
+	info, err := city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.CityName)
+}
+
+func TestReloadMmapInPlace(t *testing.T) {
+	name := saveTestDB(t, "city.ipdb", buildTestDB(t))
+	next := saveTestDB(t, "next.ipdb", buildTestDB(t, withBuild(1800000000), withCity("上海", "Shanghai")))
+
+	city, err := ipdb.NewCityMmap(name)
+	require.NoError(t, err)
+	defer city.Close()
+
+	// 文件没有被替换, 仍是正在映射的文件
+	err = city.Reload(name)
+	assert.ErrorIs(t, err, ipdb.ErrReloadInPlace)
+
+	// 通过重命名替换后可以重新加载
+	require.NoError(t, os.Rename(next, name))
+	require.NoError(t, city.Reload(name))
+	info, err := city.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "上海", info.CityName)
+}
+
+func TestCloseWhileWalking(t *testing.T) {
+	city, err := ipdb.NewCityMmap(saveTestDB(t, "city.ipdb", buildTestDB(t)))
+	require.NoError(t, err)
+
+	// 遍历中途关闭, 映射在遍历结束后才释放
+	var names []string
+	err = city.Walk("EN", func(_ *net.IPNet, info *ipdb.CityInfo) bool {
+		if len(names) == 0 {
+			require.NoError(t, city.Close())
+		}
+		names = append(names, info.CountryName)
+		return true
+	})
+	require.NoError(t, err)
+	assert.Contains(t, names, "China")
+
+	_, err = city.FindInfo("1.2.3.4", "EN")
+	assert.ErrorIs(t, err, ipdb.ErrClosed)
+	assert.ErrorIs(t, err, ipdb.ErrDatabase)
+	_, err = city.Find("1.2.3.4", "EN")
+	assert.ErrorIs(t, err, ipdb.ErrClosed)
+	assert.ErrorIs(t, city.BatchFind([]string{"1.2.3.4"}, "EN")[0].Error, ipdb.ErrClosed)
+	assert.ErrorIs(t, city.Walk("EN", func(*net.IPNet, *ipdb.CityInfo) bool { return true }), ipdb.ErrClosed)
+	assert.ErrorIs(t, city.Reload(saveTestDB(t, "next.ipdb", buildTestDB(t))), ipdb.ErrClosed)
+}
+
+func TestReloadReleasesMapping(t *testing.T) {
+	if _, err := os.Stat("/proc/self/maps"); err != nil {
+		t.Skip("/proc/self/maps not available")
+	}
+	mapped := func(name string) bool {
+		maps, err := os.ReadFile("/proc/self/maps")
+		require.NoError(t, err)
+		return strings.Contains(string(maps), name)
+	}
+
+	first := saveTestDB(t, "first.ipdb", buildTestDB(t))
+	city, err := ipdb.NewCityMmap(first)
+	require.NoError(t, err)
+	defer city.Close()
+
+	// 保留用于回滚的数据库仍然映射
+	require.NoError(t, city.Reload(saveTestDB(t, "second.ipdb", buildTestDB(t, withBuild(1800000000)))))
+	assert.True(t, mapped(first))
+
+	// 再次重新加载后, 第一个数据库不再被引用, 映射被释放
+	third := saveTestDB(t, "third.ipdb", buildTestDB(t, withBuild(1900000000)))
+	require.NoError(t, city.Reload(third))
+	assert.False(t, mapped(first))
+
+	// 回滚掉的数据库同样被释放
+	_, err = city.Rollback()
+	require.NoError(t, err)
+	assert.False(t, mapped(third))
+	assert.Equal(t, int64(1800000000), city.BuildTime().Unix())
+}
+
+// reloadingCache 第一次读取缓存时重新加载数据库, 模拟查询进行中发生的 Reload
+type reloadingCache struct {
+	ipdb.Cache
+	reload func()
+}
+
+func (c *reloadingCache) Get(key ipdb.CacheKey) (interface{}, bool) {
+	if c.reload != nil {
+		reload := c.reload
+		c.reload = nil
+		reload()
+	}
+	return c.Cache.Get(key)
+}
+
+func TestLookupErrorDuringReload(t *testing.T) {
+	city, err := ipdb.NewCity(saveTestDB(t, "old.ipdb", buildTestDB(t)))
+	require.NoError(t, err)
+	next := saveTestDB(t, "next.ipdb", buildTestDB(t, withBuild(1800000000)))
+	city.SetCache(&reloadingCache{
+		Cache:  ipdb.NewClockCache(16, 0),
+		reload: func() { require.NoError(t, city.Reload(next)) },
+	})
+
+	// 1.2.3.4 读取缓存时重新加载, 排在其后的 8.8.8.8 仍在旧快照中查询, 错误描述旧数据库
+	results := city.BatchFind([]string{"8.8.8.8", "1.2.3.4"}, "CN")
+	require.NoError(t, results[1].Error)
+	var le *ipdb.LookupError
+	require.ErrorAs(t, results[0].Error, &le)
+	assert.ErrorIs(t, le, ipdb.ErrNotFound)
+	assert.Equal(t, int64(1700000000), le.Build.Unix())
+	assert.Equal(t, int64(1800000000), city.BuildTime().Unix())
+}
@@ -1,10 +1,11 @@
 package ipdb
 
 import (
-	"errors"
-	"fmt"
-	"strconv"
-	"sync"
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
 )
 
 // RiskInfo 存储IP风险信息
@@ -16,104 +17,147 @@ type RiskInfo struct {
 
 // Risk 风险数据库结构
 type Risk struct {
-	reader *reader
-	cache  *sync.Map    // 缓存
-	mu     sync.RWMutex // 并发保护
+	database
+}
+
+// newRiskDB 使用 reader 创建数据库实例
+func newRiskDB(r *reader) (*Risk, error) {
+	db := &Risk{}
+	if err := db.init(r, &RiskInfo{}, ProductRisk); err != nil {
+		return nil, err
+	}
+	return db, nil
 }
 
 // NewRisk 创建新的风险数据库实例
 func NewRisk(filename string) (*Risk, error) {
 	if filename == "" {
-		return nil, errors.New("数据库文件名不能为空")
+		return nil, ErrFileName
 	}
 
 	reader, err := newReader(filename, &RiskInfo{})
 	if err != nil {
-		return nil, fmt.Errorf("初始化风险数据库失败: %v", err)
+		return nil, msgInit.wrap(err, "Risk")
 	}
 
-	return &Risk{
-		reader: reader,
-		cache:  &sync.Map{},
-	}, nil
+	return newRiskDB(reader)
 }
 
-// FindInfo 查询IP地址的风险信息
-func (r *Risk) FindInfo(addr string) (*RiskInfo, error) {
-	// 验证IP地址
-	if err := validateIP(addr); err != nil {
-		return nil, err
+// NewRiskMmap 通过 mmap 加载风险数据库, 使用完毕后调用 Close 释放
+func NewRiskMmap(filename string) (*Risk, error) {
+	if filename == "" {
+		return nil, ErrFileName
 	}
 
-	// 检查缓存
-	if val, ok := r.cache.Load(addr); ok {
-		if info, ok := val.(*RiskInfo); ok {
-			return info, nil
-		}
+	reader, err := newReaderMmap(filename, &RiskInfo{})
+	if err != nil {
+		return nil, msgInit.wrap(err, "Risk")
 	}
 
-	r.mu.RLock()
-	defer r.mu.RUnlock()
+	return newRiskDB(reader)
+}
 
-	// 查询数据
-	data, err := r.reader.FindMap(addr, "CN")
-	if err != nil {
-		return nil, fmt.Errorf("查询风险信息失败: %v", err)
+// NewRiskFromBytes 从字节数据创建风险数据库实例
+func NewRiskFromBytes(bs []byte) (*Risk, error) {
+	r, e := newReaderFromBytes(bs, &RiskInfo{})
+	if e != nil {
+		return nil, msgInitBytes.wrap(e, "Risk")
 	}
 
-	info := &RiskInfo{}
+	return newRiskDB(r)
+}
 
-	// 解析数据
-	if v, ok := data["score"]; ok {
-		score, err := strconv.Atoi(v)
-		if err == nil {
-			info.Score = score
-		}
+// NewRiskFromReader 从 io.Reader 创建风险数据库实例, maxSize 大于 0 时限制读取的最大字节数
+func NewRiskFromReader(rd io.Reader, maxSize int64) (*Risk, error) {
+	r, e := newReaderFromReader(rd, maxSize, &RiskInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "Risk")
 	}
-	if v, ok := data["behavior"]; ok {
-		info.Behavior = v
+
+	return newRiskDB(r)
+}
+
+// NewRiskFromReaderAt 从 io.ReaderAt 读取 size 字节创建风险数据库实例
+func NewRiskFromReaderAt(rd io.ReaderAt, size int64) (*Risk, error) {
+	r, e := newReaderFromReaderAt(rd, size, &RiskInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "Risk")
 	}
-	if v, ok := data["country_code"]; ok {
-		info.CountryCode = v
+
+	return newRiskDB(r)
+}
+
+// NewRiskFromFS 从 fs.FS 中的文件创建风险数据库实例, 可用于 embed.FS
+func NewRiskFromFS(fsys fs.FS, name string) (*Risk, error) {
+	r, e := newReaderFromFS(fsys, name, &RiskInfo{})
+	if e != nil {
+		return nil, msgInit.wrap(e, "Risk")
 	}
 
-	// 写入缓存
-	r.cache.Store(addr, info)
+	return newRiskDB(r)
+}
 
-	return info, nil
+// FindInfo 查询IP地址的风险信息
+func (r *Risk) FindInfo(addr string) (*RiskInfo, error) {
+	return r.FindInfoContext(context.Background(), addr)
 }
 
-// ClearCache 清理缓存
-func (r *Risk) ClearCache() {
-	r.cache = &sync.Map{}
+// FindInfoContext 与 FindInfo 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (r *Risk) FindInfoContext(ctx context.Context, addr string) (*RiskInfo, error) {
+	info, _, err := findInfo(ctx, &r.database, addr, "CN", decodeRiskInfo)
+	return info, err
 }
 
-// Reload 重新加载数据库
-func (r *Risk) Reload(filename string) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// decodeRiskInfo 将字段映射解析为 RiskInfo
+func decodeRiskInfo(r *reader, data []string) *RiskInfo {
+	info := &RiskInfo{}
+	r.fill(info, data)
+	return info
+}
 
-	reader, err := newReader(filename, &RiskInfo{})
-	if err != nil {
-		return fmt.Errorf("重新加载数据库失败: %v", err)
-	}
+// FindInfoWithNetwork 查询IP地址的风险信息, 同时返回命中的网络
+func (r *Risk) FindInfoWithNetwork(addr string) (*RiskInfo, *net.IPNet, error) {
+	return findInfo(context.Background(), &r.database, addr, "CN", decodeRiskInfo)
+}
 
-	r.reader = reader
-	r.ClearCache()
+// FindInfoAddr 使用 netip.Addr 查询IP地址的风险信息
+func (r *Risk) FindInfoAddr(addr netip.Addr) (*RiskInfo, error) {
+	info, _, err := r.FindInfoWithPrefix(addr)
+	return info, err
+}
+
+// FindInfoWithPrefix 使用 netip.Addr 查询风险信息, 同时返回命中的网络前缀
+func (r *Risk) FindInfoWithPrefix(addr netip.Addr) (*RiskInfo, netip.Prefix, error) {
+	return findInfoAddr(&r.database, addr, "CN", decodeRiskInfo)
+}
+
+// FindRange 返回 prefix 范围内的每个网络及其记录, 按地址顺序排列.
+// prefix 落在一个更大的网络中时, 返回 prefix 本身及该网络的记录.
+func (r *Risk) FindRange(prefix netip.Prefix) ([]RangeItem[RiskInfo], error) {
+	return r.FindRangeContext(context.Background(), prefix)
+}
+
+// FindRangeContext 与 FindRange 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (r *Risk) FindRangeContext(ctx context.Context, prefix netip.Prefix) ([]RangeItem[RiskInfo], error) {
+	return findRange(ctx, &r.database, prefix, "CN", decodeRiskInfo)
+}
+
+// Walk 遍历数据库中的每个网络及其风险信息, fn 返回 false 时停止遍历
+func (r *Risk) Walk(fn func(network *net.IPNet, info *RiskInfo) bool) error {
+	return r.WalkContext(context.Background(), fn)
+}
 
-	return nil
+// WalkContext 与 Walk 相同, 遍历过程中 ctx 取消时停止并返回 ctx 的错误
+func (r *Risk) WalkContext(ctx context.Context, fn func(network *net.IPNet, info *RiskInfo) bool) error {
+	return walkInfo(ctx, &r.database, "CN", decodeRiskInfo, fn)
 }
 
-// IsIPv4 检查是否支持IPv4
-func (r *Risk) IsIPv4() bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.reader.IsIPv4Support()
+// BatchFind 并发查询多个地址, 按输入顺序返回每个地址的结果和错误, 所有地址使用同一个数据库快照
+func (r *Risk) BatchFind(addrs []string) []BatchItem[RiskInfo] {
+	return r.BatchFindContext(context.Background(), addrs)
 }
 
-// IsIPv6 检查是否支持IPv6
-func (r *Risk) IsIPv6() bool {
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-	return r.reader.IsIPv6Support()
+// BatchFindContext 与 BatchFind 相同, ctx 取消后尚未查询的地址返回 ctx 的错误
+func (r *Risk) BatchFindContext(ctx context.Context, addrs []string) []BatchItem[RiskInfo] {
+	return batchFind(ctx, &r.database, addrs, "CN", decodeRiskInfo)
 }
@@ -0,0 +1,44 @@
+package ipdb
+
+// snapshot 数据库实例的不可变快照. 查询时原子读取当前快照, 不需要获取数据库实例的锁,
+// 但读写缓存时仍会获取缓存自身的锁. Reload、SetCache 构造新快照整体替换, 正在进行的查询继续使用旧快照.
+type snapshot struct {
+	reader  *reader
+	cache   Cache
+	product Product // 数据库的产品类型, 创建快照时确定, 用于错误信息
+}
+
+// newSnapshot 创建快照, want 为空 (自定义结构体) 时根据字段识别产品类型
+func newSnapshot(r *reader, cache Cache, want Product) *snapshot {
+	product := want
+	if product == "" {
+		product = DetectProduct(r.meta.Fields)
+	}
+	return &snapshot{reader: r, cache: cache, product: product}
+}
+
+// cacheEntry 缓存中保存的记录及其所属 reader 的编号.
+// 同一条记录可能被解析为不同类型 (例如 FindInfo 的结构体和 FindMap 的映射), 每种类型各保存一份.
+// Reload 之后缓存会被复用, 重新加载前发起的查询仍可能写入旧记录, 读取时据此丢弃.
+// 只保存编号而不引用 reader, 旧记录留在缓存中时不会使旧数据库无法回收.
+type cacheEntry struct {
+	gen    uint64
+	values []interface{}
+}
+
+// get 读取当前 reader 写入的缓存记录, 返回的切片不能修改
+func (s *snapshot) get(key CacheKey) ([]interface{}, bool) {
+	val, ok := s.cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	e, ok := val.(cacheEntry)
+	if !ok || e.gen != s.reader.gen {
+		return nil, false
+	}
+	return e.values, true
+}
+
+func (s *snapshot) set(key CacheKey, values []interface{}) {
+	s.cache.Set(key, cacheEntry{gen: s.reader.gen, values: values})
+}
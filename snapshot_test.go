@@ -0,0 +1,41 @@
+package ipdb
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// 重新加载后旧查询写入缓存的记录不会使旧 reader 无法回收
+func TestCacheEntryReleasesReader(t *testing.T) {
+	cache := NewClockCache(16, 0)
+	freed := make(chan struct{})
+	func() {
+		r, err := newReaderFromBytes(fuzzSeed(t), &CityInfo{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		runtime.SetFinalizer(r, func(*reader) { close(freed) })
+
+		s := newSnapshot(r, cache, ProductCity)
+		s.set(CacheKey{Offset: 1, Language: "CN"}, []interface{}{&CityInfo{CountryName: "中国"}})
+		if _, ok := s.get(CacheKey{Offset: 1, Language: "CN"}); !ok {
+			t.Fatal("cached record not found")
+		}
+	}()
+
+	deadline := time.After(5 * time.Second)
+	for {
+		runtime.GC()
+		select {
+		case <-freed:
+			if cache.Stats().Len != 1 {
+				t.Fatal("cache entry was dropped")
+			}
+			return
+		case <-deadline:
+			t.Fatal("reader still reachable from the cache")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
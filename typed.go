@@ -0,0 +1,149 @@
+package ipdb
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"net"
+	"net/netip"
+	"reflect"
+)
+
+var ErrRecordType = kindError("record type must be a struct", "记录类型必须是结构体", ErrInvalidInput)
+
+// Typed 将记录解析为自定义结构体 T 的数据库, 适用于内置类型之外的产品.
+// 数据库字段按结构体字段的 ipdb 标签匹配, 没有 ipdb 标签时使用 json 标签, 都没有时使用字段名.
+// 字段值转换为字段类型: 字符串原样保存, 整数、浮点数和布尔值按文本解析, 其余类型按 JSON 解析,
+// 无法转换的值保持零值.
+type Typed[T any] struct {
+	database
+}
+
+// newTyped 检查 T 是否为结构体, 并使用 reader 创建数据库实例
+func newTyped[T any](load func(obj interface{}) (*reader, error)) (*Typed[T], error) {
+	obj := new(T)
+	if reflect.TypeOf(obj).Elem().Kind() != reflect.Struct {
+		return nil, ErrRecordType
+	}
+
+	r, err := load(obj)
+	if err != nil {
+		return nil, msgInit.wrap(err, "ipdb")
+	}
+
+	return newTypedDB[T](r), nil
+}
+
+// newTypedDB 使用 reader 创建数据库实例, 自定义结构体不检查产品类型
+func newTypedDB[T any](r *reader) *Typed[T] {
+	db := &Typed[T]{}
+	db.init(r, new(T), "")
+	return db
+}
+
+// Open 加载数据库文件, 记录解析为 T
+func Open[T any](name string) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReader(name, obj)
+	})
+}
+
+// OpenMmap 通过 mmap 加载数据库文件, 使用完毕后调用 Close 释放
+func OpenMmap[T any](name string) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReaderMmap(name, obj)
+	})
+}
+
+// OpenBytes 从字节数据加载数据库
+func OpenBytes[T any](bs []byte) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReaderFromBytes(bs, obj)
+	})
+}
+
+// OpenReader 从 io.Reader 加载数据库, maxSize 大于 0 时限制读取的最大字节数
+func OpenReader[T any](rd io.Reader, maxSize int64) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReaderFromReader(rd, maxSize, obj)
+	})
+}
+
+// OpenReaderAt 从 io.ReaderAt 读取 size 字节加载数据库
+func OpenReaderAt[T any](rd io.ReaderAt, size int64) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReaderFromReaderAt(rd, size, obj)
+	})
+}
+
+// OpenFS 从 fs.FS 中的文件加载数据库, 可用于 embed.FS
+func OpenFS[T any](fsys fs.FS, name string) (*Typed[T], error) {
+	return newTyped[T](func(obj interface{}) (*reader, error) {
+		return newReaderFromFS(fsys, name, obj)
+	})
+}
+
+// FindInfo 查询IP地址, 返回解析后的记录
+func (db *Typed[T]) FindInfo(addr, language string) (*T, error) {
+	return db.FindInfoContext(context.Background(), addr, language)
+}
+
+// FindInfoContext 与 FindInfo 相同, ctx 已取消或超时时直接返回 ctx 的错误
+func (db *Typed[T]) FindInfoContext(ctx context.Context, addr, language string) (*T, error) {
+	info, _, err := findInfo(ctx, &db.database, addr, language, decodeTyped[T])
+	return info, err
+}
+
+// FindInfoWithNetwork 查询IP地址, 同时返回命中的网络
+func (db *Typed[T]) FindInfoWithNetwork(addr, language string) (*T, *net.IPNet, error) {
+	return findInfo(context.Background(), &db.database, addr, language, decodeTyped[T])
+}
+
+// FindInfoAddr 使用 netip.Addr 查询
+func (db *Typed[T]) FindInfoAddr(addr netip.Addr, language string) (*T, error) {
+	info, _, err := db.FindInfoWithPrefix(addr, language)
+	return info, err
+}
+
+// FindInfoWithPrefix 使用 netip.Addr 查询, 同时返回命中的网络前缀
+func (db *Typed[T]) FindInfoWithPrefix(addr netip.Addr, language string) (*T, netip.Prefix, error) {
+	return findInfoAddr(&db.database, addr, language, decodeTyped[T])
+}
+
+// FindRange 返回 prefix 范围内的每个网络及其记录, 按地址顺序排列.
+// prefix 落在一个更大的网络中时, 返回 prefix 本身及该网络的记录.
+func (db *Typed[T]) FindRange(prefix netip.Prefix, language string) ([]RangeItem[T], error) {
+	return db.FindRangeContext(context.Background(), prefix, language)
+}
+
+// FindRangeContext 与 FindRange 相同, 遍历过程中 ctx 取消时返回 ctx 的错误
+func (db *Typed[T]) FindRangeContext(ctx context.Context, prefix netip.Prefix, language string) ([]RangeItem[T], error) {
+	return findRange(ctx, &db.database, prefix, language, decodeTyped[T])
+}
+
+// Walk 遍历数据库中的每个网络及其记录, fn 返回 false 时停止遍历
+func (db *Typed[T]) Walk(language string, fn func(network *net.IPNet, info *T) bool) error {
+	return db.WalkContext(context.Background(), language, fn)
+}
+
+// WalkContext 与 Walk 相同, 遍历过程中 ctx 取消时停止并返回 ctx 的错误
+func (db *Typed[T]) WalkContext(ctx context.Context, language string, fn func(network *net.IPNet, info *T) bool) error {
+	return walkInfo(ctx, &db.database, language, decodeTyped[T], fn)
+}
+
+// decodeTyped 将字段值解析为 T
+func decodeTyped[T any](r *reader, data []string) *T {
+	info := new(T)
+	r.fill(info, data)
+	return info
+}
+
+// BatchFind 并发查询多个地址, 按输入顺序返回每个地址的结果和错误, 所有地址使用同一个数据库快照
+func (db *Typed[T]) BatchFind(addrs []string, language string) []BatchItem[T] {
+	return db.BatchFindContext(context.Background(), addrs, language)
+}
+
+// BatchFindContext 与 BatchFind 相同, ctx 取消后尚未查询的地址返回 ctx 的错误
+func (db *Typed[T]) BatchFindContext(ctx context.Context, addrs []string, language string) []BatchItem[T] {
+	return batchFind(ctx, &db.database, addrs, language, decodeTyped[T])
+}
@@ -0,0 +1,94 @@
+package ipdb_test
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type customInfo struct {
+	Country   string  `ipdb:"country_name"`
+	City      string  `json:"city_name"`
+	Latitude  float64 `ipdb:"latitude"`
+	ASN       int     `ipdb:"asn"`
+	Anycast   bool    `ipdb:"anycast"`
+	Users     uint32  `ipdb:"users"`
+	Ignored   string  `ipdb:"-" json:"region_name"`
+	NoMapping string
+}
+
+func buildCustomDB(t testing.TB) []byte {
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name", "latitude", "asn", "anycast", "users"}, "CN")
+	require.NoError(t, err)
+	require.NoError(t, w.InsertCIDR("1.2.3.0/24", map[string][]string{
+		"CN": {"中国", "北京", "北京", "39.9042", "4134", "1", "1024"},
+	}))
+	require.NoError(t, w.InsertCIDR("8.8.8.0/24", map[string][]string{
+		"CN": {"美国", "", "", "not-a-number", "", "N", ""},
+	}))
+	body, err := w.Bytes()
+	require.NoError(t, err)
+	return body
+}
+
+func TestTyped(t *testing.T) {
+	db, err := ipdb.OpenBytes[customInfo](buildCustomDB(t))
+	require.NoError(t, err)
+
+	info, network, err := db.FindInfoWithNetwork("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "1.2.3.0/24", network.String())
+	assert.Equal(t, &customInfo{
+		Country:  "中国",
+		City:     "北京",
+		Latitude: 39.9042,
+		ASN:      4134,
+		Anycast:  true,
+		Users:    1024,
+	}, info)
+
+	// 无法转换的值保持零值
+	info, err = db.FindInfoAddr(netip.MustParseAddr("8.8.8.8"), "CN")
+	require.NoError(t, err)
+	assert.Equal(t, &customInfo{Country: "美国"}, info)
+
+	_, err = db.FindInfo("9.9.9.9", "CN")
+	assert.ErrorIs(t, err, ipdb.ErrDataNotExists)
+
+	var count int
+	require.NoError(t, db.Walk("CN", func(_ *net.IPNet, info *customInfo) bool {
+		assert.NotEmpty(t, info.Country)
+		count++
+		return true
+	}))
+	assert.Equal(t, 2, count)
+
+	// 内置结构体同样可以使用
+	city, err := ipdb.OpenBytes[ipdb.CityInfo](buildTestDB(t))
+	require.NoError(t, err)
+	cityInfo, err := city.FindInfo("1.2.3.4", "EN")
+	require.NoError(t, err)
+	assert.Equal(t, "Beijing", cityInfo.CityName)
+}
+
+func TestTypedReaderAt(t *testing.T) {
+	body := buildCustomDB(t)
+	db, err := ipdb.OpenReaderAt[customInfo](bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	info, err := db.FindInfo("1.2.3.4", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "北京", info.City)
+
+	_, err = ipdb.OpenReaderAt[customInfo](bytes.NewReader(body[:100]), int64(len(body)))
+	assert.ErrorIs(t, err, ipdb.ErrDatabase)
+}
+
+func TestTypedNotStruct(t *testing.T) {
+	_, err := ipdb.OpenBytes[string](buildTestDB(t))
+	assert.ErrorIs(t, err, ipdb.ErrRecordType)
+}
@@ -0,0 +1,214 @@
+package ipdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"sort"
+	"time"
+)
+
+// maxVerifyProblems 报告中最多记录的问题数量, 避免损坏严重的文件产生过大的报告
+const maxVerifyProblems = 100
+
+// VerifyProblem 描述校验时发现的一个问题
+type VerifyProblem struct {
+	Node   int    `json:"node"`   // 相关节点下标, -1 表示与节点无关
+	Offset int    `json:"offset"` // 相关位置在数据区中的偏移, -1 表示未知
+	Reason string `json:"reason"`
+}
+
+func (p VerifyProblem) String() string {
+	return msgFormat.format(p.Node, p.Offset, p.Reason)
+}
+
+// VerifyReport 数据库完整性校验报告
+type VerifyReport struct {
+	Build     time.Time `json:"build"`
+	IPVersion uint16    `json:"ip_version"`
+	Languages []string  `json:"languages"`
+	Fields    []string  `json:"fields"`
+
+	NodeCount      int `json:"node_count"`      // 元数据中声明的节点数
+	ReachableNodes int `json:"reachable_nodes"` // 从根节点可达的节点数
+	TotalSize      int `json:"total_size"`      // 元数据中声明的数据区大小
+	UsedSize       int `json:"used_size"`       // 节点区与被引用记录实际占用的大小
+	Records        int `json:"records"`         // 不同记录的数量
+	Networks       int `json:"networks"`        // 叶子 (网络) 的数量
+
+	IPv4Reachable bool `json:"ipv4_reachable"` // IPv4 子树是否可达
+
+	Problems []VerifyProblem `json:"problems,omitempty"`
+	// Truncated 问题数量超过上限, 只记录了前一部分
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// OK 没有发现任何问题时返回 true
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *VerifyReport) addProblem(node, offset int, m message, args ...interface{}) {
+	if len(r.Problems) >= maxVerifyProblems {
+		r.Truncated = true
+		return
+	}
+	r.Problems = append(r.Problems, VerifyProblem{
+		Node:   node,
+		Offset: offset,
+		Reason: m.format(args...),
+	})
+}
+
+// Verify 对数据库文件做完整性校验, 逐个检查节点和记录.
+// 文件头或元数据无法解析时返回 error, 结构问题记录在报告中.
+func Verify(name string) (*VerifyReport, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	body, err := readDatabase(f, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return VerifyBytes(body)
+}
+
+// VerifyBytes 对内存中的数据库内容做完整性校验
+func VerifyBytes(body []byte) (*VerifyReport, error) {
+	if isCompressed(body) {
+		var err error
+		if body, err = readDatabase(bytes.NewReader(body), 0); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := parseHeader(body, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{
+		Build:     db.Build(),
+		IPVersion: db.meta.IPVersion,
+		Languages: db.Languages(),
+		Fields:    db.meta.Fields,
+		NodeCount: db.nodeCount,
+		TotalSize: db.meta.TotalSize,
+		UsedSize:  db.nodeCount * 8,
+	}
+	sort.Strings(report.Languages)
+
+	// 超出声明大小的数据不属于数据库, 指向其中的记录按越界处理
+	if len(db.data) > db.meta.TotalSize {
+		report.addProblem(-1, db.meta.TotalSize, msgVerifyTrailing, len(db.data)-db.meta.TotalSize, db.meta.TotalSize)
+		db.data = db.data[:db.meta.TotalSize]
+	}
+
+	expected := len(db.meta.Languages) * len(db.meta.Fields)
+	for lang, off := range db.meta.Languages {
+		if off+len(db.meta.Fields) > expected {
+			report.addProblem(-1, -1, msgVerifyLanguage, lang, off)
+		}
+	}
+
+	db.verifyTree(report, expected)
+	db.verifyIPv4(report)
+	db.verifySize(report)
+
+	return report, nil
+}
+
+// verifyTree 从根节点深度优先遍历, 检查节点下标、记录、环和共用的节点
+func (db *reader) verifyTree(report *VerifyReport, expected int) {
+	n := db.nodeCount
+	records := make(map[int]bool)
+
+	// state: 0 未访问, 1 在当前路径上, 2 已完成
+	type frame struct {
+		node  int
+		child int
+	}
+	state := make([]uint8, n)
+	stack := []frame{{node: 0}}
+	state[0] = 1
+	report.ReachableNodes = 1
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.child == 2 {
+			state[top.node] = 2
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		node := top.node
+		next := db.readNode(node, top.child)
+		top.child++
+
+		switch {
+		case next == n:
+		case next > n:
+			report.Networks++
+			if _, ok := records[next]; !ok {
+				records[next] = db.verifyRecord(report, node, next, expected)
+			}
+		case state[next] == 1:
+			report.addProblem(node, node*8, msgVerifyCycle, next)
+		case state[next] == 2:
+			report.addProblem(node, node*8, msgNodeParents, next)
+		case state[next] == 0:
+			state[next] = 1
+			report.ReachableNodes++
+			stack = append(stack, frame{node: next})
+		}
+	}
+
+	report.Records = len(records)
+	if report.ReachableNodes != n {
+		report.addProblem(-1, -1, msgVerifyReachable, n, report.ReachableNodes)
+	}
+}
+
+// verifyRecord 检查叶子指向的记录, 记录有效时返回 true
+func (db *reader) verifyRecord(report *VerifyReport, node, value, expected int) bool {
+	resolved := value - db.nodeCount + db.nodeCount*8
+	if resolved+2 > len(db.data) {
+		report.addProblem(node, resolved, msgRecordOffset)
+		return false
+	}
+	size := int(binary.BigEndian.Uint16(db.data[resolved : resolved+2]))
+	end := resolved + 2 + size
+	if end > len(db.data) {
+		report.addProblem(node, resolved, msgVerifyLength, size)
+		return false
+	}
+	if end > report.UsedSize {
+		report.UsedSize = end
+	}
+
+	if count := bytes.Count(db.data[resolved+2:end], []byte{'\t'}) + 1; count != expected {
+		report.addProblem(node, resolved, msgVerifyValues, count, expected)
+		return false
+	}
+	return true
+}
+
+// verifyIPv4 检查声明支持 IPv4 时 ::ffff:0:0/96 路径是否可达
+func (db *reader) verifyIPv4(report *VerifyReport) {
+	report.IPv4Reachable = db.ipv4Offset() != db.nodeCount
+
+	if db.IsIPv4Support() && !report.IPv4Reachable {
+		report.addProblem(-1, -1, msgVerifyIPv4)
+	}
+}
+
+// verifySize 检查节点区与记录是否用满声明的数据区, 官方数据库末尾的全零填充不算未使用
+func (db *reader) verifySize(report *VerifyReport) {
+	if len(bytes.TrimRight(db.data[report.UsedSize:], "\x00")) == 0 {
+		return
+	}
+	report.addProblem(-1, report.UsedSize, msgVerifySize, report.TotalSize, report.UsedSize)
+}
@@ -0,0 +1,85 @@
+package ipdb_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	report, err := ipdb.Verify(TEST_DB_PATH)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Problems)
+	assert.Equal(t, report.NodeCount, report.ReachableNodes)
+	assert.True(t, report.IPv4Reachable)
+	assert.NotZero(t, report.Records)
+	assert.NotZero(t, report.Networks)
+	assert.LessOrEqual(t, report.UsedSize, report.TotalSize)
+}
+
+func TestVerifyBytes(t *testing.T) {
+	body := buildTestDB(t)
+
+	report, err := ipdb.VerifyBytes(body)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Problems)
+	assert.Equal(t, []string{"CN", "EN"}, report.Languages)
+	assert.Equal(t, report.TotalSize, report.UsedSize)
+
+	// 破坏一条记录的字段分隔符
+	bad := bytes.Replace(body, []byte("China\tBeijing"), []byte("China Beijing"), 1)
+	report, err = ipdb.VerifyBytes(bad)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.Len(t, report.Problems, 1)
+
+	_, err = ipdb.VerifyBytes(body[:10])
+	assert.Error(t, err)
+}
+
+func TestVerifyBytesSize(t *testing.T) {
+	body := buildTestDB(t)
+
+	// 文件末尾多出的数据
+	report, err := ipdb.VerifyBytes(append(append([]byte(nil), body...), "junk"...))
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 1, "%v", report.Problems)
+	assert.Contains(t, report.Problems[0].Reason, "4 bytes")
+
+	// total_size 包含了多出的数据
+	metaLength := binary.BigEndian.Uint32(body[0:4])
+	var meta map[string]interface{}
+	require.NoError(t, json.Unmarshal(body[4:4+metaLength], &meta))
+	meta["total_size"] = meta["total_size"].(float64) + 4
+	head, err := json.Marshal(meta)
+	require.NoError(t, err)
+	padded := binary.BigEndian.AppendUint32(nil, uint32(len(head)))
+	padded = append(padded, head...)
+	padded = append(padded, body[4+metaLength:]...)
+
+	report, err = ipdb.VerifyBytes(append(padded, "junk"...))
+	require.NoError(t, err)
+	require.Len(t, report.Problems, 1, "%v", report.Problems)
+	assert.Equal(t, report.TotalSize-4, report.UsedSize)
+
+	// 末尾的全零填充不算问题
+	report, err = ipdb.VerifyBytes(append(padded, 0, 0, 0, 0))
+	require.NoError(t, err)
+	assert.True(t, report.OK(), "%v", report.Problems)
+}
+
+func TestVerifyBytesSharedNode(t *testing.T) {
+	body := append([]byte(nil), buildTestDB(t)...)
+	nodes := 4 + int(binary.BigEndian.Uint32(body[0:4]))
+	copy(body[nodes+4:nodes+8], body[nodes:nodes+4])
+
+	report, err := ipdb.VerifyBytes(body)
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	assert.Contains(t, report.Problems[0].Reason, "multiple parents")
+}
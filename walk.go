@@ -0,0 +1,136 @@
+package ipdb
+
+import (
+	"context"
+	"net"
+	"net/netip"
+)
+
+// walkFunc 遍历回调, 返回 false 时停止遍历
+type walkFunc func(network *net.IPNet, node int) bool
+
+// walk 遍历整棵树, 对每个叶子节点调用 fn.
+// IPv4 数据从 v4offset 开始单独遍历并以 IPv4 网络返回,
+// IPv6 遍历时跳过 ::ffff:0:0/96 子树以免重复.
+func (db *reader) walk(fn walkFunc) {
+	if db.IsIPv4Support() {
+		if !db.walkNode(db.v4offset, make(net.IP, net.IPv4len), 0, 32, fn) {
+			return
+		}
+	}
+	if db.IsIPv6Support() {
+		db.walkNode(0, make(net.IP, net.IPv6len), 0, 128, fn)
+	}
+}
+
+func (db *reader) walkNode(node int, ip net.IP, depth, bits int, fn walkFunc) bool {
+	if node == db.nodeCount {
+		return true
+	}
+	if node > db.nodeCount {
+		network := &net.IPNet{
+			IP:   append(net.IP(nil), ip...),
+			Mask: net.CIDRMask(depth, bits),
+		}
+		return fn(network, node)
+	}
+	if depth >= bits {
+		return true
+	}
+	if bits == 128 && depth == 96 && db.IsIPv4Support() && isIPv4Path(ip) {
+		return true
+	}
+
+	mask := byte(1) << (7 - uint(depth&7))
+	if !db.walkNode(db.readNode(node, 0), ip, depth+1, bits, fn) {
+		return false
+	}
+	ip[depth>>3] |= mask
+	ok := db.walkNode(db.readNode(node, 1), ip, depth+1, bits, fn)
+	ip[depth>>3] &^= mask
+
+	return ok
+}
+
+// walkPrefix 沿 prefix 下降到对应的节点, 再遍历该节点的子树.
+// prefix 整体落在一个更大的网络中时, 以 prefix 本身作为网络调用一次 fn.
+// ::ffff:0:0/96 中的前缀按 IPv4 处理; 与 walk 相同, IPv6 前缀的子树跳过 IPv4 数据.
+func (db *reader) walkPrefix(prefix netip.Prefix, fn walkFunc) error {
+	if !prefix.IsValid() {
+		return ErrIPFormat
+	}
+	addr, depth := prefix.Addr().WithZone(""), prefix.Bits()
+	if addr.Is4In6() && depth >= 96 {
+		addr, depth = addr.Unmap(), depth-96
+	}
+	addr = netip.PrefixFrom(addr, depth).Masked().Addr()
+
+	var ip net.IP
+	var node, bits int
+	if addr.Is4() {
+		if !db.IsIPv4Support() {
+			return ErrNoSupportIPv4
+		}
+		a := addr.As4()
+		ip, node, bits = a[:], db.v4offset, 32
+	} else {
+		if !db.IsIPv6Support() {
+			return ErrNoSupportIPv6
+		}
+		a := addr.As16()
+		ip, node, bits = a[:], 0, 128
+	}
+
+	for i := 0; i < depth && node < db.nodeCount; i++ {
+		node = db.readNode(node, int((ip[i>>3]>>(7-(i&7)))&1))
+	}
+	if node > db.nodeCount {
+		fn(&net.IPNet{IP: ip, Mask: net.CIDRMask(depth, bits)}, node)
+		return nil
+	}
+
+	db.walkNode(node, ip, depth, bits, fn)
+	return nil
+}
+
+// walkRecords 遍历每个网络并解析指定语言的记录, ctx 取消时停止遍历并返回 ctx 的错误
+func (db *reader) walkRecords(ctx context.Context, language string, fn func(network *net.IPNet, data []string) bool) error {
+	if !db.hasLanguage(language) {
+		return ErrNoSupportLanguage
+	}
+
+	var err error
+	db.walk(db.records(ctx, language, fn, &err))
+
+	return err
+}
+
+// walkPrefixRecords 遍历 prefix 范围内的每个网络并解析指定语言的记录
+func (db *reader) walkPrefixRecords(ctx context.Context, prefix netip.Prefix, language string, fn func(network *net.IPNet, data []string) bool) error {
+	if !db.hasLanguage(language) {
+		return ErrNoSupportLanguage
+	}
+
+	var err error
+	if e := db.walkPrefix(prefix, db.records(ctx, language, fn, &err)); e != nil {
+		return e
+	}
+
+	return err
+}
+
+// records 返回解析叶子节点记录的 walkFunc, 读取失败或 ctx 取消时将错误写入 err 并停止遍历
+func (db *reader) records(ctx context.Context, language string, fn func(network *net.IPNet, data []string) bool, err *error) walkFunc {
+	return func(network *net.IPNet, node int) bool {
+		if e := ctx.Err(); e != nil {
+			*err = e
+			return false
+		}
+		data, e := db.record(node, language)
+		if e != nil {
+			*err = e
+			return false
+		}
+		return fn(network, data)
+	}
+}
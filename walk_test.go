@@ -0,0 +1,63 @@
+package ipdb_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCity_Walk(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	var v4Addrs uint64
+	found := make(map[string]string)
+	err = city.Walk("EN", func(network *net.IPNet, info *ipdb.CityInfo) bool {
+		ones, bits := network.Mask.Size()
+		if bits == 32 {
+			v4Addrs += 1 << uint(32-ones)
+		}
+		found[network.String()] = info.CountryName
+		return true
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(1<<24), v4Addrs)
+	assert.Equal(t, "China", found["1.2.3.0/24"])
+	assert.Equal(t, "Australia", found["1.0.0.0/15"])
+	assert.Equal(t, "Reserved", found["2001:db8::/32"])
+	for cidr := range found {
+		assert.NotContains(t, cidr, "::ffff:")
+	}
+}
+
+func TestCity_WalkStop(t *testing.T) {
+	count := 0
+	err := db.Walk("CN", func(network *net.IPNet, info *ipdb.CityInfo) bool {
+		count++
+		return count < 10
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 10, count)
+
+	assert.ErrorIs(t, db.Walk("XX", func(*net.IPNet, *ipdb.CityInfo) bool { return true }), ipdb.ErrNoSupportLanguage)
+}
+
+func TestCity_WalkMatchesFind(t *testing.T) {
+	count := 0
+	err := db.Walk("CN", func(network *net.IPNet, info *ipdb.CityInfo) bool {
+		count++
+		if count%5000 == 0 {
+			got, err := db.FindInfo(network.IP.String(), "CN")
+			require.NoError(t, err)
+			assert.Equal(t, info.CountryName, got.CountryName)
+			assert.Equal(t, info.CityName, got.CityName)
+		}
+		return true
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, count)
+}
@@ -0,0 +1,266 @@
+package ipdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	ErrWriterFields    = kindError("field list must not be empty", "字段列表不能为空", ErrInvalidInput)
+	ErrWriterLanguages = kindError("language list must not be empty", "语言列表不能为空", ErrInvalidInput)
+	ErrWriterValues    = kindError("number of values does not match the fields", "字段值数量与字段列表不匹配", ErrInvalidInput)
+	ErrWriterRecord    = kindError("record is too long or contains invalid characters", "记录内容过长或包含非法字符", ErrInvalidInput)
+	// ErrWriterPrefix ::/0 会使树根成为叶子, ipdb 格式无法表示, 需要拆成 ::/1 和 8000::/1 插入
+	ErrWriterPrefix = kindError("::/0 cannot be stored, insert ::/1 and 8000::/1 instead", "无法写入 ::/0, 请分别插入 ::/1 和 8000::/1", ErrInvalidInput)
+)
+
+// recordPadding 记录区开头保留的空白字节数, 与官方数据库文件布局一致,
+// 保证任何有效记录的偏移都大于 0 (节点值等于 node_count 表示数据不存在)
+const recordPadding = 16
+
+// writerNode 构建过程中使用的二叉树节点
+type writerNode struct {
+	children [2]*writerNode
+	record   string
+	leaf     bool
+}
+
+// Writer 用于生成 ipdb 格式的数据库文件
+type Writer struct {
+	fields    []string
+	languages []string
+	ipVersion uint16
+	build     time.Time
+
+	root *writerNode
+}
+
+// NewWriter 创建数据库写入器, languages 的顺序决定记录中各语言数据的排列顺序
+func NewWriter(fields []string, languages ...string) (*Writer, error) {
+	if len(fields) == 0 {
+		return nil, ErrWriterFields
+	}
+	if len(languages) == 0 {
+		return nil, ErrWriterLanguages
+	}
+
+	return &Writer{
+		fields:    append([]string(nil), fields...),
+		languages: append([]string(nil), languages...),
+		build:     time.Now(),
+		root:      &writerNode{},
+	}, nil
+}
+
+// SetBuildTime 设置写入元数据中的构建时间
+func (w *Writer) SetBuildTime(t time.Time) {
+	w.build = t
+}
+
+// InsertCIDR 以 CIDR 字符串形式插入网络, values 以语言为键, 值按字段顺序排列
+func (w *Writer) InsertCIDR(cidr string, values map[string][]string) error {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return msgParseCIDR.wrap(err)
+	}
+	return w.Insert(network, values)
+}
+
+// Insert 插入网络及其各语言的字段值, 后插入的网络会覆盖与之重叠的已有数据
+func (w *Writer) Insert(network *net.IPNet, values map[string][]string) error {
+	record, err := w.encodeRecord(values)
+	if err != nil {
+		return err
+	}
+
+	ip, bits, ok := writerPath(network)
+	if !ok {
+		return ErrIPFormat
+	}
+	if bits == 0 {
+		return ErrWriterPrefix
+	}
+	if bits >= 96 && isIPv4Path(ip) {
+		w.ipVersion |= IPv4
+	} else {
+		w.ipVersion |= IPv6
+	}
+
+	node := w.root
+	for i := 0; i < bits; i++ {
+		bit := (ip[i>>3] >> (7 - uint(i&7))) & 1
+		if node.leaf {
+			// 拆分已有的更大网络, 两侧继承原记录
+			node.children[0] = &writerNode{record: node.record, leaf: true}
+			node.children[1] = &writerNode{record: node.record, leaf: true}
+			node.leaf = false
+			node.record = ""
+		}
+		if node.children[bit] == nil {
+			node.children[bit] = &writerNode{}
+		}
+		node = node.children[bit]
+	}
+
+	node.children = [2]*writerNode{}
+	node.record = record
+	node.leaf = true
+
+	return nil
+}
+
+// writerPath 返回网络在树中的查找路径及前缀位数, IPv4 网络挂在 ::ffff:0:0/96 下
+func writerPath(network *net.IPNet) (net.IP, int, bool) {
+	if network == nil {
+		return nil, 0, false
+	}
+	ones, size := network.Mask.Size()
+	if ip := network.IP.To4(); ip != nil && size == 32 {
+		path := make(net.IP, net.IPv6len)
+		copy(path, net.IPv4(ip[0], ip[1], ip[2], ip[3]).To16())
+		return path, 96 + ones, true
+	}
+	if ip := network.IP.To16(); ip != nil && size == 128 {
+		return ip, ones, true
+	}
+	return nil, 0, false
+}
+
+// isIPv4Path 判断路径是否位于 ::ffff:0:0/96 之下
+func isIPv4Path(ip net.IP) bool {
+	for i := 0; i < 10; i++ {
+		if ip[i] != 0 {
+			return false
+		}
+	}
+	return ip[10] == 0xff && ip[11] == 0xff
+}
+
+func (w *Writer) encodeRecord(values map[string][]string) (string, error) {
+	parts := make([]string, 0, len(w.languages)*len(w.fields))
+	for _, lang := range w.languages {
+		v, ok := values[lang]
+		if !ok {
+			return "", fmt.Errorf("%w: %s", ErrNoSupportLanguage, lang)
+		}
+		if len(v) != len(w.fields) {
+			return "", ErrWriterValues
+		}
+		for _, s := range v {
+			if strings.ContainsAny(s, "\t\n") {
+				return "", ErrWriterRecord
+			}
+		}
+		parts = append(parts, v...)
+	}
+
+	record := strings.Join(parts, "\t")
+	if len(record) > 0xffff {
+		return "", ErrWriterRecord
+	}
+	return record, nil
+}
+
+// WriteTo 将数据库写入 out
+func (w *Writer) WriteTo(out io.Writer) (int64, error) {
+	// 按先序给内部节点编号
+	var nodes []*writerNode
+	index := make(map[*writerNode]int)
+	stack := []*writerNode{w.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		index[n] = len(nodes)
+		nodes = append(nodes, n)
+		for i := 1; i >= 0; i-- {
+			if c := n.children[i]; c != nil && !c.leaf {
+				stack = append(stack, c)
+			}
+		}
+	}
+	nodeCount := len(nodes)
+
+	// 去重写入记录区
+	var records bytes.Buffer
+	records.Write(make([]byte, recordPadding))
+	offsets := make(map[string]int)
+	var buf [4]byte
+
+	var tree bytes.Buffer
+	tree.Grow(nodeCount * 8)
+	for _, n := range nodes {
+		for _, c := range n.children {
+			value := nodeCount
+			switch {
+			case c == nil:
+			case c.leaf:
+				off, ok := offsets[c.record]
+				if !ok {
+					off = records.Len()
+					offsets[c.record] = off
+					binary.BigEndian.PutUint16(buf[:2], uint16(len(c.record)))
+					records.Write(buf[:2])
+					records.WriteString(c.record)
+				}
+				value = nodeCount + off
+			default:
+				value = index[c]
+			}
+			binary.BigEndian.PutUint32(buf[:], uint32(value))
+			tree.Write(buf[:])
+		}
+	}
+
+	languages := make(map[string]int, len(w.languages))
+	for i, lang := range w.languages {
+		languages[lang] = i * len(w.fields)
+	}
+	meta, err := json.Marshal(MetaData{
+		Build:     w.build.Unix(),
+		IPVersion: w.ipVersion,
+		Languages: languages,
+		NodeCount: nodeCount,
+		TotalSize: tree.Len() + records.Len(),
+		Fields:    w.fields,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	binary.BigEndian.PutUint32(buf[:], uint32(len(meta)))
+	var total int64
+	for _, part := range [][]byte{buf[:], meta, tree.Bytes(), records.Bytes()} {
+		n, err := out.Write(part)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// Bytes 返回完整的数据库内容
+func (w *Writer) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := w.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Save 将数据库写入指定文件
+func (w *Writer) Save(name string) error {
+	body, err := w.Bytes()
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, body, 0644)
+}
@@ -0,0 +1,141 @@
+package ipdb_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/soulteary/ipdb-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testDBOption 在 buildTestDB 写入默认数据之后调整数据库
+type testDBOption func(w *ipdb.Writer) error
+
+// withBuild 修改构建时间
+func withBuild(sec int64) testDBOption {
+	return func(w *ipdb.Writer) error {
+		w.SetBuildTime(time.Unix(sec, 0))
+		return nil
+	}
+}
+
+// withCity 将 1.2.3.0/24 改为中国的另一个城市
+func withCity(cn, en string) testDBOption {
+	return func(w *ipdb.Writer) error {
+		return w.InsertCIDR("1.2.3.0/24", map[string][]string{
+			"CN": {"中国", cn, cn},
+			"EN": {"China", en, en},
+		})
+	}
+}
+
+func buildTestDB(t testing.TB, opts ...testDBOption) []byte {
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name"}, "CN", "EN")
+	require.NoError(t, err)
+	w.SetBuildTime(time.Unix(1700000000, 0))
+
+	require.NoError(t, w.InsertCIDR("1.0.0.0/8", map[string][]string{
+		"CN": {"澳大利亚", "", ""},
+		"EN": {"Australia", "", ""},
+	}))
+	require.NoError(t, w.InsertCIDR("1.2.3.0/24", map[string][]string{
+		"CN": {"中国", "北京", "北京"},
+		"EN": {"China", "Beijing", "Beijing"},
+	}))
+	require.NoError(t, w.InsertCIDR("2001:db8::/32", map[string][]string{
+		"CN": {"保留地址", "", ""},
+		"EN": {"Reserved", "", ""},
+	}))
+	for _, opt := range opts {
+		require.NoError(t, opt(w))
+	}
+
+	body, err := w.Bytes()
+	require.NoError(t, err)
+	return body
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	city, err := ipdb.NewCityFromBytes(buildTestDB(t))
+	require.NoError(t, err)
+
+	assert.True(t, city.IsIPv4())
+	assert.True(t, city.IsIPv6())
+	assert.Equal(t, int64(1700000000), city.BuildTime().Unix())
+	assert.ElementsMatch(t, []string{"CN", "EN"}, city.Languages())
+
+	info, err := city.FindInfo("1.2.3.4", "EN")
+	require.NoError(t, err)
+	assert.Equal(t, "China", info.CountryName)
+	assert.Equal(t, "Beijing", info.CityName)
+
+	info, err = city.FindInfo("1.2.4.1", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "澳大利亚", info.CountryName)
+
+	info, err = city.FindInfo("2001:db8::1", "EN")
+	require.NoError(t, err)
+	assert.Equal(t, "Reserved", info.CountryName)
+
+	_, err = city.FindInfo("8.8.8.8", "CN")
+	assert.Error(t, err)
+}
+
+func TestWriter_Save(t *testing.T) {
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name", "owner_domain", "isp_domain", "idc"}, "CN")
+	require.NoError(t, err)
+	require.NoError(t, w.InsertCIDR("10.0.0.0/8", map[string][]string{
+		"CN": {"局域网", "", "", "", "", "IDC"},
+	}))
+
+	name := filepath.Join(t.TempDir(), "idc.ipdb")
+	require.NoError(t, w.Save(name))
+
+	idc, err := ipdb.NewIDC(name)
+	require.NoError(t, err)
+	assert.True(t, idc.IsIPv4())
+	assert.False(t, idc.IsIPv6())
+
+	info, err := idc.FindInfo("10.1.2.3", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "IDC", info.IDC)
+}
+
+func TestWriter_InvalidValues(t *testing.T) {
+	_, err := ipdb.NewWriter(nil, "CN")
+	assert.ErrorIs(t, err, ipdb.ErrWriterFields)
+
+	w, err := ipdb.NewWriter([]string{"country_name"}, "CN")
+	require.NoError(t, err)
+	assert.ErrorIs(t, w.InsertCIDR("1.0.0.0/8", map[string][]string{"CN": {"a", "b"}}), ipdb.ErrWriterValues)
+	assert.ErrorIs(t, w.InsertCIDR("1.0.0.0/8", map[string][]string{"CN": {"a\tb"}}), ipdb.ErrWriterRecord)
+	assert.ErrorIs(t, w.InsertCIDR("1.0.0.0/8", map[string][]string{"EN": {"a"}}), ipdb.ErrNoSupportLanguage)
+	assert.Error(t, w.InsertCIDR("not-a-cidr", map[string][]string{"CN": {"a"}}))
+	assert.ErrorIs(t, w.InsertCIDR("::/0", map[string][]string{"CN": {"a"}}), ipdb.ErrWriterPrefix)
+}
+
+func TestWriter_DefaultRoute(t *testing.T) {
+	w, err := ipdb.NewWriter([]string{"country_name", "region_name", "city_name"}, "CN")
+	require.NoError(t, err)
+	// ::/0 需要拆成两半插入, 查询时报告各自的网络
+	for _, cidr := range []string{"::/1", "8000::/1"} {
+		require.NoError(t, w.InsertCIDR(cidr, map[string][]string{"CN": {"全球", "", ""}}))
+	}
+	require.NoError(t, w.InsertCIDR("0.0.0.0/0", map[string][]string{"CN": {"IPv4", "", ""}}))
+	body, err := w.Bytes()
+	require.NoError(t, err)
+
+	city, err := ipdb.NewCityFromBytes(body)
+	require.NoError(t, err)
+	info, network, err := city.FindInfoWithNetwork("8001::1", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "全球", info.CountryName)
+	assert.Equal(t, "8000::/1", network.String())
+
+	info, network, err = city.FindInfoWithNetwork("8.8.8.8", "CN")
+	require.NoError(t, err)
+	assert.Equal(t, "IPv4", info.CountryName)
+	assert.Equal(t, "0.0.0.0/0", network.String())
+}